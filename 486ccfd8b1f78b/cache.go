@@ -0,0 +1,182 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultTokenCacheSize = 1000            // キャッシュするトークン数の既定値 (TOKEN_CACHE_SIZE)
+	defaultTokenCacheTTL  = 5 * time.Minute // 検証結果を再利用する時間の既定値 (TOKEN_CACHE_TTL)
+)
+
+// キャッシュのヒット率は expvar で管理用のポートの /debug/vars に公開される (serveMetrics)
+var (
+	tokenCacheHits   = expvar.NewInt("token_cache_hits")   // キャッシュから返した回数
+	tokenCacheMisses = expvar.NewInt("token_cache_misses") // /userinfo で検証した回数
+	tokenCacheShared = expvar.NewInt("token_cache_shared") // 同時に来た同じトークンの検証結果を共有した回数
+)
+
+var tokenCache *validationCache
+
+// validationCache は /userinfo で検証済みのトークン → ユーザー情報を、トークンのハッシュをキーに保持する LRU キャッシュ
+type validationCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // キー → order の要素
+	order   *list.List               // 先頭ほど最近使われた *cacheEntry
+	group   singleflight.Group
+}
+
+// cacheEntry はキャッシュの 1 件。検証結果と一緒にトークンの有効期限を持つ。
+type cacheEntry struct {
+	key         string
+	userInfo    *UserInfoResponse
+	cachedUntil time.Time // この時刻まで検証結果を再利用する
+	tokenExpiry time.Time // トークン自体の有効期限 (わからなければゼロ値)
+}
+
+func init() {
+	expvar.Publish("token_cache_hit_rate", expvar.Func(func() interface{} {
+		hits, misses := tokenCacheHits.Value(), tokenCacheMisses.Value()
+		if hits+misses == 0 {
+			return 0.0
+		}
+		return float64(hits) / float64(hits+misses)
+	}))
+}
+
+// initializeTokenCache は環境変数 TOKEN_CACHE_SIZE・TOKEN_CACHE_TTL (例: 5m) からキャッシュを作る
+func initializeTokenCache() {
+	size := defaultTokenCacheSize
+	if v := os.Getenv("TOKEN_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatal("Invalid TOKEN_CACHE_SIZE: ", err)
+		}
+		size = n
+	}
+	ttl := defaultTokenCacheTTL
+	if v := os.Getenv("TOKEN_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatal("Invalid TOKEN_CACHE_TTL: ", err)
+		}
+		ttl = d
+	}
+	tokenCache = newValidationCache(size, ttl)
+}
+
+func newValidationCache(size int, ttl time.Duration) *validationCache {
+	return &validationCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// tokenKey はトークンそのものをメモリに残さないよう SHA-256 のハッシュをキーにする
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateOpaqueTokenCached は validate (プロバイダの /userinfo などでの検証) の結果をキャッシュする。
+// expiry はトークン交換で得たアクセストークンの有効期限 (わからなければゼロ値) で、キャッシュする期間の上限にする。
+// 同じトークンの検証が同時に来た場合は validate の呼び出しを 1 回にまとめる。
+// ctx が終了した呼び出し元はその時点でエラーを返すが、共有している検証は他の呼び出し元のために最後まで行う。
+func validateOpaqueTokenCached(ctx context.Context, token string, expiry time.Time, validate func(context.Context, string) (*UserInfoResponse, error)) (*UserInfoResponse, error) {
+	if tokenCache == nil || tokenCache.size <= 0 {
+		return validate(ctx, token)
+	}
+	key := tokenKey(token)
+	if userInfo := tokenCache.get(key); userInfo != nil {
+		tokenCacheHits.Add(1)
+		return userInfo, nil
+	}
+
+	validateCtx := context.WithoutCancel(ctx) // 検証の時間は httpClient のタイムアウトで制限される
+	validated := false                        // この呼び出し自身が validate したか (Shared は validate した側でも true になる)
+	ch := tokenCache.group.DoChan(key, func() (interface{}, error) {
+		validated = true
+		tokenCacheMisses.Add(1)
+		userInfo, err := validate(validateCtx, token)
+		if err != nil {
+			return nil, err // 失敗した結果はキャッシュしない
+		}
+		tokenCache.put(key, userInfo, expiry)
+		return userInfo, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Shared && !validated {
+			tokenCacheShared.Add(1)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*UserInfoResponse), nil
+	}
+}
+
+// get は有効期間内のキャッシュされたユーザー情報を返す (なければ nil)
+func (c *validationCache) get(key string) *UserInfoResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.cachedUntil) {
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.userInfo
+}
+
+// put は検証結果とトークンの有効期限 (わからなければゼロ値) を保存する。
+// 再利用する期間は ttl とトークンの残りの有効期限の短い方にする。
+func (c *validationCache) put(key string, userInfo *UserInfoResponse, tokenExpiry time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry(key)
+	entry.userInfo = userInfo
+	entry.tokenExpiry = tokenExpiry
+	entry.cachedUntil = time.Now().Add(c.ttl)
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(entry.cachedUntil) {
+		entry.cachedUntil = tokenExpiry
+	}
+}
+
+// entry は key の要素を最近使われたものとして返す。なければ追加し、上限を超えた古い要素を捨てる。
+// 呼び出し側で mu をロックしておくこと。
+func (c *validationCache) entry(key string) *cacheEntry {
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*cacheEntry)
+	}
+	entry := &cacheEntry{key: key}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return entry
+}
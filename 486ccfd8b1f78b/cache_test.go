@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useTestTokenCache はテストの間だけ tokenCache を新しいキャッシュに置き換え、ヒット数などの expvar を 0 に戻す
+func useTestTokenCache(t *testing.T, size int, ttl time.Duration) *validationCache {
+	t.Helper()
+	saved := tokenCache
+	tokenCache = newValidationCache(size, ttl)
+	t.Cleanup(func() { tokenCache = saved })
+	for _, v := range []*expvar.Int{tokenCacheHits, tokenCacheMisses, tokenCacheShared} {
+		v.Set(0)
+	}
+	return tokenCache
+}
+
+func TestValidationCacheEviction(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []string // "+a" は a を保存、"?a" は a を参照
+		want map[string]bool
+	}{
+		{"oldest is evicted", []string{"+a", "+b", "+c"}, map[string]bool{"a": false, "b": true, "c": true}},
+		{"get marks as recently used", []string{"+a", "+b", "?a", "+c"}, map[string]bool{"a": true, "b": false, "c": true}},
+		{"put marks as recently used", []string{"+a", "+b", "+a", "+c"}, map[string]bool{"a": true, "b": false, "c": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newValidationCache(2, time.Minute)
+			for _, op := range tt.ops {
+				key := op[1:]
+				if op[0] == '+' {
+					c.put(key, &UserInfoResponse{Sub: key}, time.Time{})
+				} else {
+					c.get(key)
+				}
+			}
+			for key, want := range tt.want {
+				if got := c.get(key) != nil; got != want {
+					t.Errorf("cached %s = %v, want %v", key, got, want)
+				}
+			}
+			if c.order.Len() != 2 || len(c.entries) != 2 {
+				t.Errorf("cache holds %d/%d entries, want 2", c.order.Len(), len(c.entries))
+			}
+		})
+	}
+}
+
+func TestValidationCacheTTL(t *testing.T) {
+	const ttl = 5 * time.Minute
+	now := time.Now()
+	tests := []struct {
+		name        string
+		tokenExpiry time.Time
+		wantCached  bool
+		wantUntil   time.Time // ゼロ値なら now + ttl 前後
+	}{
+		{"unknown expiry uses ttl", time.Time{}, true, time.Time{}},
+		{"later expiry uses ttl", now.Add(time.Hour), true, time.Time{}},
+		{"earlier expiry caps ttl", now.Add(time.Minute), true, now.Add(time.Minute)},
+		{"expired token is not reused", now.Add(-time.Second), false, now.Add(-time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newValidationCache(10, ttl)
+			c.put("key", &UserInfoResponse{Sub: "user-1"}, tt.tokenExpiry)
+
+			entry := c.entries["key"].Value.(*cacheEntry)
+			if !entry.tokenExpiry.Equal(tt.tokenExpiry) {
+				t.Errorf("tokenExpiry = %v, want %v", entry.tokenExpiry, tt.tokenExpiry)
+			}
+			if tt.wantUntil.IsZero() {
+				if d := entry.cachedUntil.Sub(now); d < ttl || d > ttl+time.Minute {
+					t.Errorf("cachedUntil is %v after now, want about %v", d, ttl)
+				}
+			} else if !entry.cachedUntil.Equal(tt.wantUntil) {
+				t.Errorf("cachedUntil = %v, want %v", entry.cachedUntil, tt.wantUntil)
+			}
+			if got := c.get("key") != nil; got != tt.wantCached {
+				t.Errorf("cached = %v, want %v", got, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestValidateOpaqueTokenCachedSingleflight(t *testing.T) {
+	useTestTokenCache(t, 10, time.Minute)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	validate := func(ctx context.Context, token string) (*UserInfoResponse, error) {
+		calls.Add(1)
+		<-release
+		return &UserInfoResponse{Sub: "user-1"}, nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userInfo, err := validateOpaqueTokenCached(context.Background(), "token", time.Time{}, validate)
+			if err != nil || userInfo.Sub != "user-1" {
+				t.Errorf("validateOpaqueTokenCached = %v, %v", userInfo, err)
+			}
+		}()
+	}
+	// 最初の検証が始まってから、残りの呼び出しがそれに合流するまで待って結果を返す
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("validate called %d times, want 1", got)
+	}
+	if got := tokenCacheShared.Value(); got != n-1 {
+		t.Errorf("token_cache_shared = %d, want %d", got, n-1)
+	}
+}
+
+func TestValidateOpaqueTokenCachedErrorsAreNotCached(t *testing.T) {
+	useTestTokenCache(t, 10, time.Minute)
+
+	calls := 0
+	validate := func(ctx context.Context, token string) (*UserInfoResponse, error) {
+		calls++
+		return nil, errors.New("invalid token")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := validateOpaqueTokenCached(context.Background(), "token", time.Time{}, validate); err == nil {
+			t.Fatal("validateOpaqueTokenCached succeeded for an invalid token")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("validate called %d times, want 2", calls)
+	}
+}
+
+func TestValidateOpaqueTokenCachedCancel(t *testing.T) {
+	useTestTokenCache(t, 10, time.Minute)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	validate := func(ctx context.Context, token string) (*UserInfoResponse, error) {
+		close(started)
+		<-release
+		// 呼び出し元のリクエストが終了しても、共有している検証は打ち切らない
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &UserInfoResponse{Sub: "user-1"}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := validateOpaqueTokenCached(ctx, "token", time.Time{}, validate)
+		canceled <- err
+	}()
+	<-started
+	cancel()
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	// 打ち切られなかった検証の結果はキャッシュされている
+	for tokenCache.get(tokenKey("token")) == nil {
+		time.Sleep(time.Millisecond)
+	}
+	userInfo, err := validateOpaqueTokenCached(context.Background(), "token", time.Time{}, validate)
+	if err != nil || userInfo.Sub != "user-1" {
+		t.Errorf("validateOpaqueTokenCached after cancel = %v, %v", userInfo, err)
+	}
+}
+
+func TestTokenCacheHitRate(t *testing.T) {
+	useTestTokenCache(t, 10, time.Minute)
+	hitRate := expvar.Get("token_cache_hit_rate")
+	if got := hitRate.String(); got != "0" {
+		t.Errorf("hit rate before any validation = %s, want 0", got)
+	}
+
+	validate := func(ctx context.Context, token string) (*UserInfoResponse, error) {
+		return &UserInfoResponse{Sub: token}, nil
+	}
+	for _, token := range []string{"a", "a", "a", "a", "b"} {
+		if _, err := validateOpaqueTokenCached(context.Background(), token, time.Time{}, validate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits, misses := tokenCacheHits.Value(), tokenCacheMisses.Value(); hits != 3 || misses != 2 {
+		t.Errorf("hits, misses = %d, %d, want 3, 2", hits, misses)
+	}
+	if got := hitRate.String(); got != "0.6" {
+		t.Errorf("token_cache_hit_rate = %s, want 0.6", got)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultRolesClaim は Auth0 の RBAC を有効にしたときにアクセストークンに入る権限のクレーム
+const defaultRolesClaim = "permissions"
+
+// rolesClaim はロール・権限を読み取るクレーム名 (ROLES_CLAIM で変更できる。例: https://example.com/roles)
+var rolesClaim = defaultRolesClaim
+
+// adminRole は /admin の利用に必要なロール・権限 (ADMIN_ROLE で変更できる。例: read:admin)
+var adminRole = "admin"
+
+// contextKey はリクエストのコンテキストに値を入れるためのキーの型 (他のパッケージのキーと衝突しないように非公開にする)
+type contextKey int
+
+const (
+	userInfoKey contextKey = iota
+	claimsKey
+)
+
+// initializeRolesClaim は環境変数 ROLES_CLAIM からロールのクレーム名を、ADMIN_ROLE から /admin に必要なロールを読み込む
+func initializeRolesClaim() {
+	if claim := os.Getenv("ROLES_CLAIM"); claim != "" {
+		rolesClaim = claim
+	}
+	if role := os.Getenv("ADMIN_ROLE"); role != "" {
+		adminRole = role
+	}
+}
+
+// withUser は認証済みユーザーの情報とクレームをコンテキストに入れる
+func withUser(ctx context.Context, userInfo *UserInfoResponse) context.Context {
+	ctx = context.WithValue(ctx, userInfoKey, userInfo)
+	return context.WithValue(ctx, claimsKey, userInfo.Claims)
+}
+
+// userFromContext は validateTokenMiddleware がコンテキストに入れたユーザー情報を返す
+func userFromContext(ctx context.Context) (*UserInfoResponse, bool) {
+	userInfo, ok := ctx.Value(userInfoKey).(*UserInfoResponse)
+	return userInfo, ok && userInfo != nil
+}
+
+// claimsFromContext はトークン (JWT のクレームまたは /userinfo のレスポンス) の生のクレームを返す
+func claimsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	claims, ok := ctx.Value(claimsKey).(map[string]interface{})
+	return claims, ok
+}
+
+// rolesFromContext は rolesClaim のクレームからロール・権限の一覧を返す。
+// クレームは文字列の配列、またはスペース区切りの文字列 (scope と同じ形式) を受け付ける。
+func rolesFromContext(ctx context.Context) []string {
+	claims, ok := claimsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	switch v := claims[rolesClaim].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var roles []string
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+// hasRole はユーザーが role を持っているかを返す
+func hasRole(ctx context.Context, role string) bool {
+	for _, r := range rolesFromContext(ctx) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// requireRole は role を持つユーザーのリクエストだけを next に渡す (validateTokenMiddleware の内側で使う)
+func requireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := userFromContext(r.Context()); !ok {
+			http.Error(w, "Unauthorized: No user in context", http.StatusUnauthorized)
+			return
+		}
+		if !hasRole(r.Context(), role) {
+			http.Error(w, fmt.Sprintf("Forbidden: %q role is required", role), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// useRolesClaim はテストの間だけ ROLES_CLAIM を設定してクレーム名を読み込み直す
+func useRolesClaim(t *testing.T, claim string) {
+	t.Helper()
+	savedClaim, savedRole := rolesClaim, adminRole
+	t.Cleanup(func() { rolesClaim, adminRole = savedClaim, savedRole })
+	t.Setenv("ROLES_CLAIM", claim)
+	initializeRolesClaim()
+}
+
+func userContext(claims map[string]interface{}) context.Context {
+	return withUser(context.Background(), &UserInfoResponse{Sub: "user-1", Claims: claims})
+}
+
+func TestRolesFromContext(t *testing.T) {
+	const customClaim = "https://example.com/roles"
+	tests := []struct {
+		name   string
+		claim  string // ROLES_CLAIM (空なら既定の permissions)
+		claims map[string]interface{}
+		want   []string
+	}{
+		{"array claim", "", map[string]interface{}{"permissions": []interface{}{"read:admin", "write:admin"}}, []string{"read:admin", "write:admin"}},
+		{"space separated string", "", map[string]interface{}{"permissions": "read:admin  write:admin"}, []string{"read:admin", "write:admin"}},
+		{"non-string elements are skipped", "", map[string]interface{}{"permissions": []interface{}{"admin", 1.0, nil}}, []string{"admin"}},
+		{"ROLES_CLAIM override", customClaim, map[string]interface{}{customClaim: []interface{}{"admin"}, "permissions": "read:admin"}, []string{"admin"}},
+		{"ROLES_CLAIM override with string", customClaim, map[string]interface{}{customClaim: "admin editor"}, []string{"admin", "editor"}},
+		{"default claim is ignored after override", customClaim, map[string]interface{}{"permissions": "read:admin"}, nil},
+		{"missing claim", "", map[string]interface{}{"sub": "user-1"}, nil},
+		{"unsupported claim type", "", map[string]interface{}{"permissions": map[string]interface{}{"admin": true}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRolesClaim(t, tt.claim)
+			if got := rolesFromContext(userContext(tt.claims)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rolesFromContext = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := rolesFromContext(context.Background()); got != nil {
+		t.Errorf("rolesFromContext without a user = %q, want nil", got)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	useRolesClaim(t, "")
+	handler := requireRole("admin", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{
+		{"no user", context.Background(), http.StatusUnauthorized},
+		{"without role", userContext(map[string]interface{}{"permissions": []interface{}{"read:admin"}}), http.StatusForbidden},
+		{"with role", userContext(map[string]interface{}{"permissions": []interface{}{"read:admin", "admin"}}), http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/admin", nil).WithContext(tt.ctx))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body: %s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
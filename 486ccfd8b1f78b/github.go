@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+// githubAPIURL は GitHub の REST API のベース URL
+var githubAPIURL = "https://api.github.com"
+
+// githubProvider は GitHub の OAuth App / GitHub App によるログイン。
+// GitHub は OpenID Connect に対応していないので ID トークンはなく、ユーザー情報は REST API から取得する。
+type githubProvider struct {
+	config *oauth2.Config
+}
+
+// newGitHubProvider は GITHUB_CLIENT_ID・GITHUB_CLIENT_SECRET・GITHUB_CALLBACK_URL からプロバイダを作る
+func newGitHubProvider() *githubProvider {
+	return &githubProvider{
+		config: &oauth2.Config{
+			ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+			RedirectURL:  callbackURL("github", "GITHUB"),
+			Endpoint:     github.Endpoint,
+			Scopes:       []string{"read:user", "user:email"},
+		},
+	}
+}
+
+func (p *githubProvider) Name() string {
+	return "github"
+}
+
+func (p *githubProvider) OAuth2Config() *oauth2.Config {
+	return p.config
+}
+
+// AuthCodeURL は PKCE の code_challenge を付けた認可 URL を返す (ID トークンがないので nonce は使わない)
+func (p *githubProvider) AuthCodeURL(state, verifier, nonce string) string {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange は認可コードをトークンに交換する。ID トークンはないのでクレームは nil を返す。
+func (p *githubProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*oauth2.Token, map[string]interface{}, error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to exchange token: %v", err)
+	}
+	return token, nil, nil
+}
+
+// ValidateToken は GitHub の /user API でトークンを検証する (結果はキャッシュする)
+func (p *githubProvider) ValidateToken(ctx context.Context, token string, expiry time.Time) (*UserInfoResponse, error) {
+	return validateOpaqueTokenCached(ctx, token, expiry, githubUserInfo)
+}
+
+// RevokeToken は未対応 (GitHub のトークン失効 API はリフレッシュトークンを受け付けない)
+func (p *githubProvider) RevokeToken(ctx context.Context, refreshToken string) error {
+	return errors.New("github: refresh token revocation is not supported")
+}
+
+// LogoutURL は GitHub 側のセッションを終了させられないので、アプリケーションに戻る URL を返す
+func (p *githubProvider) LogoutURL(session *Session) string {
+	return logoutReturnURL
+}
+
+// githubUserInfo は /user (メールアドレスが非公開なら /user/emails も) からユーザー情報を取得する
+func githubUserInfo(ctx context.Context, token string) (*UserInfoResponse, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	claims, err := githubGet(ctx, token, "/user", &user)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("invalid token: no user id in GitHub response")
+	}
+
+	userInfo := &UserInfoResponse{
+		Sub:   fmt.Sprintf("github|%d", user.ID), // 他のプロバイダの sub と衝突しないように接頭辞を付ける
+		Name:  user.Name,
+		Email: user.Email,
+	}
+	userInfo.Claims, _ = claims.(map[string]interface{})
+	if userInfo.Name == "" {
+		userInfo.Name = user.Login
+	}
+	if userInfo.Email == "" {
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if _, err := githubGet(ctx, token, "/user/emails", &emails); err == nil {
+			for _, e := range emails {
+				if e.Primary && e.Verified {
+					userInfo.Email = e.Email
+				}
+			}
+		}
+	}
+	return userInfo, nil
+}
+
+// githubGet は GitHub の API を呼び出してレスポンスを v にデコードし、生の JSON の値も返す
+func githubGet(ctx context.Context, token, path string, v interface{}) (interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", githubAPIURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call GitHub %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid token: received status %d from GitHub %s", resp.StatusCode, path)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub %s response: %v", path, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub %s response: %v", path, err)
+	}
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub %s response: %v", path, err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFakeGitHubAPI はテストの間だけ githubAPIURL を /user と /user/emails を返すテスト用のサーバに向ける
+func useFakeGitHubAPI(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	saved := githubAPIURL
+	githubAPIURL = server.URL
+	t.Cleanup(func() { githubAPIURL = saved })
+}
+
+func TestGitHubUserInfo(t *testing.T) {
+	useFakeGitHubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_token" {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			// メールアドレスが非公開のユーザー
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "login": "octocat", "name": ""})
+		case "/user/emails":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"email": "old@example.com", "primary": false, "verified": true},
+				{"email": "octocat@example.com", "primary": true, "verified": true},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	userInfo, err := githubUserInfo(context.Background(), "gho_token")
+	if err != nil {
+		t.Fatalf("githubUserInfo: %v", err)
+	}
+	if userInfo.Sub != "github|42" || userInfo.Name != "octocat" || userInfo.Email != "octocat@example.com" {
+		t.Errorf("userInfo = %+v", userInfo)
+	}
+	if login, _ := userInfo.Claims["login"].(string); login != "octocat" {
+		t.Errorf("login claim = %q, want octocat", login)
+	}
+
+	if _, err := githubUserInfo(context.Background(), "revoked"); err == nil {
+		t.Error("githubUserInfo accepted a token the API rejected")
+	}
+}
+
+func TestGitHubUserInfoCanceled(t *testing.T) {
+	release := make(chan struct{})
+	useFakeGitHubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release // 応答しない API
+	})
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := githubUserInfo(ctx, "gho_token"); err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("githubUserInfo error = %v, want the request context's deadline", err)
+	}
+}
@@ -7,4 +7,5 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/oauth2 v0.25.0
+	golang.org/x/sync v0.10.0
 )
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	jwksRefreshInterval = time.Hour        // JWKS をバックグラウンドで取り直す間隔
+	jwksMinRefreshWait  = 30 * time.Second // 未知の kid による取り直しの最短間隔 (不正なトークンで JWKS を連打させない)
+)
+
+// jwksCache はプロバイダの公開鍵 (JWKS) を kid ごとに保持する
+type jwksCache struct {
+	url   string
+	group singleflight.Group // 同時に来た取り直しを 1 回のリクエストにまとめる
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	attemptedAt time.Time // 最後に取得を試みた時刻 (失敗した場合も更新し、取り直しの間隔を空ける)
+}
+
+// jsonWebKey は JWKS の 1 つの鍵
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// refreshLoop は interval ごとに JWKS を取り直す (鍵のローテーションに追従するため)
+func (c *jwksCache) refreshLoop(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := c.refresh(); err != nil {
+			log.Printf("Failed to refresh JWKS: %v", err)
+		}
+	}
+}
+
+// refresh は JWKS エンドポイントから RS256 の署名鍵を取得してキャッシュを置き換える。
+// 同時に呼ばれた場合は取得を 1 回にまとめ、その結果を共有する。
+func (c *jwksCache) refresh() error {
+	_, err, _ := c.group.Do("jwks", func() (interface{}, error) {
+		err := c.fetch()
+		c.mu.Lock()
+		c.attemptedAt = time.Now()
+		c.mu.Unlock()
+		return nil, err
+	})
+	return err
+}
+
+// fetch は JWKS を取得して、成功した場合だけキャッシュの鍵を置き換える
+func (c *jwksCache) fetch() error {
+	resp, err := httpClient.Get(c.url)
+	if err != nil {
+		return fmt.Errorf("failed to call JWKS endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %v", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := rsaPublicKey(k)
+		if err != nil {
+			log.Printf("Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return nil
+}
+
+// key は kid に対応する公開鍵を返す。見つからない場合は鍵がローテーションされた可能性があるので JWKS を取り直す。
+// 直前の取得から jwksMinRefreshWait 経っていなければ (失敗していても) 取り直さない。
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	attemptedAt := c.attemptedAt
+	c.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(attemptedAt) >= jwksMinRefreshWait {
+		if err := c.refresh(); err != nil {
+			return nil, err
+		}
+		c.mu.RLock()
+		key, ok = c.keys[kid]
+		c.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// rsaPublicKey は JWK の n (modulus) と e (exponent) から RSA 公開鍵を組み立てる
+func rsaPublicKey(k jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %v", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %v", err)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// validateJWT は RS256 の署名を JWKS の公開鍵で検証し、iss・aud・exp・nbf を確認する (exp は必須)
+func (p *oidcProvider) validateJWT(token string) (*UserInfoResponse, error) {
+	parser := &jwt.Parser{ValidMethods: []string{"RS256"}}
+	claims := jwt.MapClaims{}
+	// exp・nbf・iat は Parse 内の claims.Valid() で確認される (ただしクレームがある場合だけ)
+	_, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.jwks.key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	// Parse は exp がなくても通すので、期限のないトークンを受け付けないようここで確認する
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("invalid token: missing exp")
+	}
+
+	if !p.verifyIssuer(claims) {
+		return nil, errors.New("invalid token: unexpected issuer")
+	}
+	// Auth0 のアクセストークンは aud が配列になることがあるので自前で確認する
+	if !hasAudience(claims["aud"], p.audience) {
+		return nil, errors.New("invalid token: unexpected audience")
+	}
+
+	userInfo := &UserInfoResponse{Claims: claims}
+	userInfo.Sub, _ = claims["sub"].(string)
+	userInfo.Email, _ = claims["email"].(string)
+	userInfo.Name, _ = claims["name"].(string)
+	if userInfo.Sub == "" {
+		return nil, errors.New("invalid token: missing sub")
+	}
+	return userInfo, nil
+}
+
+// hasAudience は aud クレーム (文字列または文字列の配列) に audience が含まれるかを返す
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// signAccessToken は fake の鍵で RS256 のアクセストークンを作る
+func signAccessToken(t *testing.T, fake *fakeAuthServer, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(fake.key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func TestValidateJWT(t *testing.T) {
+	fake := newFakeAuthServer(t, "test-client")
+	p := newOIDCProviderWithMetadata("fake", fake.metadata(), "FAKE")
+	p.audience = fakeAudience
+
+	claims := func(exp interface{}) jwt.MapClaims {
+		c := jwt.MapClaims{"iss": fake.server.URL, "aud": p.audience, "sub": "user-1"}
+		if exp != nil {
+			c["exp"] = exp
+		}
+		return c
+	}
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr string // 空なら成功
+	}{
+		{"valid", claims(time.Now().Add(time.Hour).Unix()), ""},
+		{"expired", claims(time.Now().Add(-time.Hour).Unix()), "expired"},
+		{"missing exp", claims(nil), "missing exp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userInfo, err := p.validateJWT(signAccessToken(t, fake, tt.claims))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateJWT: %v", err)
+				}
+				if userInfo.Sub != "user-1" {
+					t.Errorf("sub = %q, want user-1", userInfo.Sub)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// countingJWKSServer は fake の JWKS を返し、呼ばれた回数を数えるサーバ。fail を true にすると 500 を返す。
+type countingJWKSServer struct {
+	fake    *fakeAuthServer
+	server  *httptest.Server
+	calls   atomic.Int32
+	fail    atomic.Bool
+	release chan struct{} // nil でなければ閉じられるまで応答を待たせる
+}
+
+func newCountingJWKSServer(t *testing.T, release chan struct{}) *countingJWKSServer {
+	t.Helper()
+	s := &countingJWKSServer{fake: newFakeAuthServer(t, "test-client"), release: release}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		if s.release != nil {
+			<-s.release
+		}
+		if s.fail.Load() {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		s.fake.jwks(w, r)
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func TestJWKSRefreshWaitsAfterFailure(t *testing.T) {
+	s := newCountingJWKSServer(t, nil)
+	s.fail.Store(true)
+	c := &jwksCache{url: s.server.URL}
+
+	if _, err := c.key("test-key"); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Fatalf("key error = %v, want the JWKS endpoint failure", err)
+	}
+	// 失敗した直後は取り直さない (不正なトークンや障害中のプロバイダに JWKS を連打しない)
+	s.fail.Store(false)
+	if _, err := c.key("test-key"); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("key error = %v, want unknown signing key", err)
+	}
+	if got := s.calls.Load(); got != 1 {
+		t.Fatalf("JWKS endpoint called %d times, want 1", got)
+	}
+
+	// jwksMinRefreshWait が経てば取り直す
+	c.mu.Lock()
+	c.attemptedAt = time.Now().Add(-jwksMinRefreshWait)
+	c.mu.Unlock()
+	if _, err := c.key("test-key"); err != nil {
+		t.Fatalf("key after the wait: %v", err)
+	}
+	if got := s.calls.Load(); got != 2 {
+		t.Errorf("JWKS endpoint called %d times, want 2", got)
+	}
+}
+
+func TestJWKSRefreshSingleflight(t *testing.T) {
+	release := make(chan struct{})
+	s := newCountingJWKSServer(t, release)
+	c := &jwksCache{url: s.server.URL}
+
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := c.key("test-key")
+			errs <- err
+		}()
+	}
+	// 最初の取得が始まってから、残りの呼び出しがそれに合流するまで待って応答を返す
+	for s.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("key: %v", err)
+		}
+	}
+	if got := s.calls.Load(); got != 1 {
+		t.Errorf("JWKS endpoint called %d times, want 1", got)
+	}
+}
+
+func TestJWKSRefreshTimeout(t *testing.T) {
+	release := make(chan struct{})
+	s := newCountingJWKSServer(t, release)
+	t.Cleanup(func() { close(release) })
+
+	saved := httpClient
+	httpClient = &http.Client{Timeout: 50 * time.Millisecond}
+	t.Cleanup(func() { httpClient = saved })
+
+	c := &jwksCache{url: s.server.URL}
+	if err := c.refresh(); err == nil || !strings.Contains(err.Error(), "Client.Timeout") {
+		t.Errorf("refresh error = %v, want a client timeout", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// logoutReturnURL は認可サーバでのログアウト後に戻る URL (LOGOUT_RETURN_URL)
+var logoutReturnURL string
+
+// initializeLogout はログアウト後に戻る URL を設定する
+func initializeLogout() {
+	logoutReturnURL = os.Getenv("LOGOUT_RETURN_URL")
+	if logoutReturnURL == "" {
+		logoutReturnURL = "http://localhost:8000"
+	}
+}
+
+// revokeRefreshToken は認可サーバのリボケーションエンドポイント (RFC 7009) でリフレッシュトークンを失効させる
+func revokeRefreshToken(ctx context.Context, endpoint string, config *oauth2.Config, token string) error {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"refresh_token"},
+		"client_id":       {config.ClientID},
+		"client_secret":   {config.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call revocation endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("revocation failed: status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// logoutRequest は session のクッキーを付けて /logout をルーター経由で呼び出す
+func logoutRequest(method string, session *Session) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.ID})
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLogout(t *testing.T) {
+	t.Setenv("LOGOUT_RETURN_URL", "http://localhost:8000/bye")
+	initializeLogout()
+	fake := newFakeAuthServer(t, "test-client")
+	useFakeProvider(t, fake)
+	session := saveRefreshableSession(t, "refresh-token")
+
+	// GET では実行しない (他のサイトのリンクや <img> でログアウトさせられないように)
+	if rec := logoutRequest("GET", session); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /logout status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if _, err := sessionStore.Get(session.ID); err != nil {
+		t.Fatalf("GET /logout deleted the session: %v", err)
+	}
+	if len(fake.revoked) != 0 {
+		t.Fatalf("GET /logout revoked %q", fake.revoked)
+	}
+
+	rec := logoutRequest("POST", session)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "http://localhost:8000/bye" {
+		t.Errorf("POST /logout = %d to %q, want %d to the return URL", rec.Code, rec.Header().Get("Location"), http.StatusSeeOther)
+	}
+	if len(fake.revoked) != 1 || fake.revoked[0] != "refresh-token" {
+		t.Errorf("revoked tokens = %q, want [refresh-token]", fake.revoked)
+	}
+	if _, err := sessionStore.Get(session.ID); !errors.Is(err, errSessionNotFound) {
+		t.Errorf("session after logout: %v, want errSessionNotFound", err)
+	}
+	if c := sessionCookie(rec); c == nil || c.MaxAge >= 0 {
+		t.Errorf("session cookie = %v, want it to be cleared", c)
+	}
+}
+
+func TestRevokeRefreshTokenTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // 応答しない認可サーバ
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	saved := httpClient
+	httpClient = &http.Client{Timeout: 50 * time.Millisecond}
+	t.Cleanup(func() { httpClient = saved })
+
+	err := revokeRefreshToken(context.Background(), server.URL, &oauth2.Config{ClientID: "test-client"}, "refresh-token")
+	if err == nil || !strings.Contains(err.Error(), "Client.Timeout") {
+		t.Errorf("revokeRefreshToken error = %v, want a client timeout", err)
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -10,22 +14,31 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
-	"golang.org/x/oauth2"
 )
 
-// グローバル変数
-var oauthConfig *oauth2.Config
-
 // UserInfoResponse は /userinfo エンドポイントのレスポンスを格納する構造体
 type UserInfoResponse struct {
 	Sub   string `json:"sub"`   // ユーザーID
 	Email string `json:"email"` // メールアドレス
 	Name  string `json:"name"`  // ユーザー名
+
+	Claims map[string]interface{} `json:"-"` // トークンの生のクレーム (ロールなどの参照用)
 }
 
-func init() {
-	loadEnvVariables()      // 環境変数をロード
-	initializeOAuthConfig() // OAuth2設定を初期化
+// httpClient は認可サーバへのリクエストに使う。
+// 応答しないサーバに対してリクエストを処理するゴルーチンが止まったままにならないよう、タイムアウトを設定する。
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// initialize は設定を読み込み、各機能を初期化する。
+// テストから main パッケージを読み込んでも .env や認可サーバを必要としないよう、init ではなく main から呼ぶ。
+func initialize() {
+	loadEnvVariables()       // 環境変数をロード
+	initializeProviders()    // 認可サーバ (Auth0・Google・GitHub・OIDC) の設定を初期化
+	initializeStateKeys()    // state クッキーの署名鍵を初期化
+	initializeTokenCache()   // /userinfo の検証結果のキャッシュを初期化
+	initializeRolesClaim()   // ロール・権限を読み取るクレーム名を初期化
+	initializeSessionStore() // トークンを保存するセッションストアを初期化
+	initializeLogout()       // ログアウト後に戻る URL を初期化
 }
 
 func loadEnvVariables() {
@@ -35,105 +48,189 @@ func loadEnvVariables() {
 	}
 }
 
-func initializeOAuthConfig() {
-	// Auth0のドメインを取得
-	auth0Domain := os.Getenv("AUTH0_DOMAIN")
-	// OAuth2設定を構築
-	oauthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("AUTH0_CLIENT_ID"),
-		ClientSecret: os.Getenv("AUTH0_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("AUTH0_CALLBACK_URL"),
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  fmt.Sprintf("https://%s/authorize", auth0Domain),
-			TokenURL: fmt.Sprintf("https://%s/oauth/token", auth0Domain),
-		},
-		Scopes: []string{"openid", "profile", "email"},
-	}
+func main() {
+	initialize()
+
+	// 管理用のエンドポイント (expvar の /debug/vars) は公開するポートとは別に、ローカルホストだけで提供する
+	go serveMetrics()
+
+	log.Println("Server started at http://localhost:3000")
+	// http.DefaultServeMux は expvar が /debug/vars を登録するので使わず、ルーターだけを公開する
+	log.Fatal(http.ListenAndServe(":3000", corsMiddleware(newRouter()))) // CORS設定をミドルウェアで追加
 }
 
-func main() {
+// newRouter は公開するエンドポイントのルーターを作る
+func newRouter() *mux.Router {
 	r := mux.NewRouter()
 
 	// エンドポイント定義
-	r.HandleFunc("/login", loginHandler)                                                // ログイン処理
-	r.HandleFunc("/callback", callbackHandler)                                          // コールバック処理
-	r.Handle("/protected", validateTokenMiddleware(http.HandlerFunc(protectedHandler))) // 保護されたリソース
-	r.HandleFunc("/logout", logoutHandler)                                              // ログアウト処理
-
-	http.Handle("/", corsMiddleware(r)) // CORS設定をミドルウェアで追加
+	r.HandleFunc("/login", loginHandler)                                                                // ログイン処理 (既定のプロバイダ)
+	r.HandleFunc("/login/{provider}", loginHandler)                                                     // プロバイダを指定したログイン処理
+	r.HandleFunc("/callback", callbackHandler)                                                          // コールバック処理 (既定のプロバイダ)
+	r.HandleFunc("/callback/{provider}", callbackHandler)                                               // プロバイダを指定したコールバック処理
+	r.Handle("/protected", validateTokenMiddleware(http.HandlerFunc(protectedHandler)))                 // 保護されたリソース
+	r.Handle("/admin", validateTokenMiddleware(requireRole(adminRole, http.HandlerFunc(adminHandler)))) // 管理者ロールが必要なリソース
+	// ログアウトはリフレッシュトークンを失効させるので、他のサイトのリンクや <img> から GET で実行されないよう POST に限る
+	r.HandleFunc("/logout", logoutHandler).Methods("POST") // ログアウト処理
+	return r
+}
 
-	log.Println("Server started at http://localhost:3000")
-	log.Fatal(http.ListenAndServe(":3000", nil))
+// serveMetrics はキャッシュのヒット率などのメトリクス (expvar) を METRICS_ADDR (既定は localhost:3001) で提供する。
+// コマンドライン引数やメモリの統計も含むので、認証なしで外部から読めるポートには載せない。
+func serveMetrics() {
+	addr := os.Getenv("METRICS_ADDR")
+	if addr == "" {
+		addr = "localhost:3001"
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Printf("Metrics available at http://%s/debug/vars", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics server stopped: %v", err)
+	}
 }
 
 func loginHandler(w http.ResponseWriter, r *http.Request) {
+	// URL のプロバイダ名 (なければ既定のプロバイダ) を取得
+	p, err := lookupProvider(mux.Vars(r)["provider"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// ログインごとにランダムな state・PKCE の code_verifier・nonce を生成し、署名付きクッキーに保存
+	state, verifier, nonce, err := newState(w, p.Name())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// 認証URLを生成しリダイレクト
-	state := "exampleState"
-	url := oauthConfig.AuthCodeURL(state)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	http.Redirect(w, r, p.AuthCodeURL(state, verifier, nonce), http.StatusTemporaryRedirect)
 }
 
 func callbackHandler(w http.ResponseWriter, r *http.Request) {
-	// CSRF保護のためのstate確認
-	if state := r.URL.Query().Get("state"); state != "exampleState" {
-		http.Error(w, "Invalid state parameter", http.StatusBadRequest)
+	p, err := lookupProvider(mux.Vars(r)["provider"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// CSRF保護のためのstate確認 (クッキーの state と照合し、クッキーは削除する)
+	verifier, nonce, err := verifyState(w, r, p.Name())
+	if err != nil {
+		http.Error(w, "Invalid state parameter: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 認証コードを取得しトークン交換
+	// 認証コードを取得しトークン交換 (ID トークンを発行するプロバイダでは ID トークンも検証する)
 	code := r.URL.Query().Get("code")
-	token, err := oauthConfig.Exchange(r.Context(), code)
+	token, idClaims, err := p.Exchange(r.Context(), code, verifier, nonce)
 	if err != nil {
-		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	// クッキーにアクセストークンを保存
-	setAuthCookie(w, token.AccessToken)
+	// トークンはサーバ側のセッションに保存し、クッキーにはセッション ID だけを入れる
+	session, err := newSession()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session.Provider = p.Name()
+	session.AccessToken = token.AccessToken
+	session.RefreshToken = token.RefreshToken
+	session.TokenExpiry = token.Expiry
+	session.IDToken, _ = token.Extra("id_token").(string)
+	session.IDClaims = idClaims
+	if err := sessionStore.Save(session); err != nil {
+		http.Error(w, "Failed to save session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setSessionCookie(w, session)
 	http.Redirect(w, r, "http://localhost:8000", http.StatusSeeOther)
 }
 
-func setAuthCookie(w http.ResponseWriter, token string) {
-	// HttpOnlyクッキーを設定
-	cookie := &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // ローカル環境ではfalse、本番環境ではtrue
-		MaxAge:   3600,  // 有効期限を1時間に設定
-		SameSite: http.SameSiteStrictMode,
-	}
-	http.SetCookie(w, cookie)
-}
-
 func logoutHandler(w http.ResponseWriter, r *http.Request) {
-	// クッキーを無効化してログアウト処理
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false,
-		MaxAge:   -1,
-		Expires:  time.Unix(0, 0),
-	})
+	// リフレッシュトークンを失効させてからセッションを削除し、クッキーを無効化する
+	var session *Session
+	p, _ := lookupProvider("") // セッションがなければ既定のプロバイダ (それもなければ nil)
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		session, _ = sessionStore.Get(cookie.Value)
+		if session != nil {
+			if sp, err := lookupProvider(session.Provider); err == nil {
+				p = sp
+			}
+		}
+		if p != nil && session != nil && session.RefreshToken != "" {
+			if err := p.RevokeToken(r.Context(), session.RefreshToken); err != nil {
+				log.Printf("Failed to revoke refresh token: %v", err)
+			}
+		}
+		if err := sessionStore.Delete(cookie.Value); err != nil {
+			log.Printf("Failed to delete session: %v", err)
+		}
+	}
+	clearSessionCookie(w)
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Logout successful"))
+	// 認可サーバ側のセッションも終了させる (残っていると次の /login で自動的に再ログインされる)
+	returnURL := logoutReturnURL
+	if p != nil {
+		returnURL = p.LogoutURL(session)
+	}
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
 }
 
 func validateTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// クッキーからトークンを取得
-		cookie, err := r.Cookie("auth_token")
+		// クッキーのセッション ID からトークンを取得
+		cookie, err := r.Cookie(sessionCookieName)
 		if err != nil {
-			http.Error(w, "Unauthorized: No token found", http.StatusUnauthorized)
+			http.Error(w, "Unauthorized: No session found", http.StatusUnauthorized)
 			return
 		}
+		session, err := sessionStore.Get(cookie.Value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+			return
+		}
+
+		// アクセストークンの期限が近ければリフレッシュトークンで更新し、クッキーを発行し直す
+		if needsRefresh(session) {
+			refreshed, err := refreshSession(r.Context(), session)
+			if err != nil {
+				log.Printf("Failed to refresh session: %v", err)
+				if errors.Is(err, errRefreshTokenRejected) {
+					// セッションは削除済みなので、クッキーも消してログインし直してもらう
+					clearSessionCookie(w)
+					http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+					return
+				}
+				if time.Now().After(session.TokenExpiry) {
+					http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+					return
+				}
+				// まだ期限内なら今のトークンで続ける
+			} else {
+				session = refreshed
+				setSessionCookie(w, session)
+			}
+		}
 
-		// トークンを検証
-		userInfo, err := validateOpaqueToken(cookie.Value)
+		// ログインに使ったプロバイダでトークンを検証
+		p, err := lookupProvider(session.Provider)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+			return
+		}
+		userInfo, err := p.ValidateToken(r.Context(), session.AccessToken, session.TokenExpiry)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+			return
+		}
+
+		// ID トークンのクレームで名前・メールアドレスを補い、アクセストークンと同じユーザーであることを確認する
+		userInfo, err = applyIDTokenClaims(userInfo, session.IDClaims)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
 			return
@@ -141,28 +238,49 @@ func validateTokenMiddleware(next http.Handler) http.Handler {
 
 		// 認証成功時のログ
 		log.Printf("Authenticated user: %s (%s)", userInfo.Name, userInfo.Email)
-		next.ServeHTTP(w, r)
+		// 後続のハンドラがユーザー情報を参照できるようにコンテキストに入れる
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), userInfo)))
 	})
 }
 
 func protectedHandler(w http.ResponseWriter, r *http.Request) {
+	// validateTokenMiddleware がコンテキストに入れたユーザー情報を取得
+	userInfo, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized: No user in context", http.StatusUnauthorized)
+		return
+	}
+
 	// 保護されたリソースにアクセス成功時のレスポンス
-	w.Write([]byte("You have accessed a protected resource!"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "You have accessed a protected resource!",
+		"sub":     userInfo.Sub,
+		"name":    userInfo.Name,
+		"email":   userInfo.Email,
+		"roles":   rolesFromContext(r.Context()),
+	})
 }
 
-func validateOpaqueToken(token string) (*UserInfoResponse, error) {
-	// Auth0の/userinfoエンドポイントを使用してトークンを検証
-	domain := os.Getenv("AUTH0_DOMAIN")
-	userinfoURL := fmt.Sprintf("https://%s/userinfo", domain)
+func adminHandler(w http.ResponseWriter, r *http.Request) {
+	// requireRole で adminRole を持つことを確認済み
+	userInfo, _ := userFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "You have accessed an admin resource!",
+		"sub":     userInfo.Sub,
+	})
+}
 
-	req, err := http.NewRequest("GET", userinfoURL, nil)
+func validateOpaqueToken(ctx context.Context, userinfoURL, token string) (*UserInfoResponse, error) {
+	// プロバイダの/userinfoエンドポイントを使用してトークンを検証
+	req, err := http.NewRequestWithContext(ctx, "GET", userinfoURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call /userinfo endpoint: %v", err)
 	}
@@ -172,9 +290,16 @@ func validateOpaqueToken(token string) (*UserInfoResponse, error) {
 		return nil, fmt.Errorf("invalid token: received status %d", resp.StatusCode)
 	}
 
-	// ユーザー情報をデコード
+	// ユーザー情報をデコード (クレームは生の値も保持する)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /userinfo response: %v", err)
+	}
 	var userInfo UserInfoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
+	if err := json.Unmarshal(body, &userInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode /userinfo response: %v", err)
+	}
+	if err := json.Unmarshal(body, &userInfo.Claims); err != nil {
 		return nil, fmt.Errorf("failed to decode /userinfo response: %v", err)
 	}
 
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+// fakeAuthServer は認可エンドポイントとトークンエンドポイントを持つテスト用の認可サーバ。
+// 認可リクエストの code_challenge を覚えておき、トークンリクエストの code_verifier と照合する (RFC 7636 4.6)。
+// リフレッシュトークンは使うたびに新しいものを発行し、古いものは使えなくする (ローテーション)。
+type fakeAuthServer struct {
+	t        *testing.T
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	mu            sync.Mutex
+	challenges    map[string]string // 認可コード → code_challenge
+	nonces        map[string]string // 認可コード → nonce
+	refreshTokens map[string]bool   // 使用できるリフレッシュトークン
+	exchanged     int               // code_verifier の照合に成功したトークン交換の回数
+	refreshes     int               // refresh_token グラントのリクエスト数 (拒否したものも含む)
+	revoked       []string          // リボケーションエンドポイントで失効させたトークン
+}
+
+func newFakeAuthServer(t *testing.T, clientID string) *fakeAuthServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	f := &fakeAuthServer{
+		t:             t,
+		key:           key,
+		clientID:      clientID,
+		challenges:    make(map[string]string),
+		nonces:        make(map[string]string),
+		refreshTokens: make(map[string]bool),
+	}
+	m := http.NewServeMux()
+	m.HandleFunc("/authorize", f.authorize)
+	m.HandleFunc("/token", f.token)
+	m.HandleFunc("/jwks", f.jwks)
+	m.HandleFunc("/revoke", f.revoke)
+	f.server = httptest.NewServer(m)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeAuthServer) metadata() *providerMetadata {
+	return &providerMetadata{
+		Issuer:                f.server.URL,
+		AuthorizationEndpoint: f.server.URL + "/authorize",
+		TokenEndpoint:         f.server.URL + "/token",
+		JWKSURI:               f.server.URL + "/jwks",
+		RevocationEndpoint:    f.server.URL + "/revoke",
+	}
+}
+
+// authorize はユーザーがログインに同意したものとして、認可コードを付けて redirect_uri にリダイレクトする
+func (f *fakeAuthServer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if got := q.Get("code_challenge_method"); got != "S256" {
+		f.t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+	challenge := q.Get("code_challenge")
+	if challenge == "" {
+		f.t.Error("authorization request has no code_challenge")
+	}
+	if q.Get("code_verifier") != "" {
+		f.t.Error("authorization request must not contain the code_verifier")
+	}
+
+	code := "code-" + q.Get("state")
+	f.mu.Lock()
+	f.challenges[code] = challenge
+	f.nonces[code] = q.Get("nonce")
+	f.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	redirect.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token は code_verifier の SHA-256 が認可リクエストの code_challenge と一致する場合だけトークンを発行する
+func (f *fakeAuthServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("grant_type") == "refresh_token" {
+		f.refresh(w, r)
+		return
+	}
+	code := r.PostForm.Get("code")
+	f.mu.Lock()
+	challenge, ok := f.challenges[code]
+	nonce := f.nonces[code]
+	delete(f.challenges, code) // 認可コードは一度だけ使える
+	f.mu.Unlock()
+	if !ok {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		f.t.Errorf("base64url(sha256(code_verifier)) does not match code_challenge %q", challenge)
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.server.URL,
+		"aud":   f.clientID,
+		"sub":   "user-1",
+		"name":  "Test User",
+		"nonce": nonce,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	idToken.Header["kid"] = "test-key"
+	signed, err := idToken.SignedString(f.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	f.mu.Lock()
+	f.exchanged++
+	f.refreshTokens["refresh-token"] = true
+	f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  "access-token",
+		"refresh_token": "refresh-token",
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+		"id_token":      signed,
+	})
+}
+
+// refresh はリフレッシュトークンが有効であれば、新しいアクセストークン (JWT) とリフレッシュトークンを発行する
+func (f *fakeAuthServer) refresh(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.refreshes++
+	old := r.PostForm.Get("refresh_token")
+	valid := f.refreshTokens[old]
+	delete(f.refreshTokens, old) // 一度使ったリフレッシュトークンは再利用できない
+	next := fmt.Sprintf("refresh-token-%d", f.refreshes)
+	if valid {
+		f.refreshTokens[next] = true
+	}
+	f.mu.Unlock()
+	if !valid {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	accessToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": f.server.URL,
+		"aud": fakeAudience,
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	accessToken.Header["kid"] = "test-key"
+	signed, err := accessToken.SignedString(f.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  signed,
+		"refresh_token": next,
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+	})
+}
+
+func (f *fakeAuthServer) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []jsonWebKey{{
+			Kid: "test-key",
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+}
+
+// revoke は失効を求められたトークンを記録する (RFC 7009)
+func (f *fakeAuthServer) revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.revoked = append(f.revoked, r.PostForm.Get("token"))
+	f.mu.Unlock()
+}
+
+func writeTokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// fakeAudience は fake がリフレッシュで発行する JWT のアクセストークンの aud
+const fakeAudience = "https://api.example.com"
+
+// useFakeProvider は fake を認可サーバとする OIDC プロバイダを "fake" の名前で登録する
+func useFakeProvider(t *testing.T, fake *fakeAuthServer) *oidcProvider {
+	t.Helper()
+	t.Setenv("FAKE_CLIENT_ID", fake.clientID)
+	t.Setenv("FAKE_CLIENT_SECRET", "test-secret")
+	p := newOIDCProviderWithMetadata("fake", fake.metadata(), "FAKE")
+	providers["fake"] = p
+	t.Cleanup(func() { delete(providers, "fake") })
+
+	savedStore := sessionStore
+	sessionStore = newMemorySessionStore()
+	t.Cleanup(func() { sessionStore = savedStore })
+	return p
+}
+
+func TestLoginCallbackWithPKCE(t *testing.T) {
+	useTestStateKeys(t)
+	fake := newFakeAuthServer(t, "test-client")
+	useFakeProvider(t, fake)
+
+	// /login/fake: state クッキーを発行し、認可エンドポイントにリダイレクトする
+	loginReq := mux.SetURLVars(httptest.NewRequest("GET", "/login/fake", nil), map[string]string{"provider": "fake"})
+	loginRec := httptest.NewRecorder()
+	loginHandler(loginRec, loginReq)
+	if loginRec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("login status = %d, want %d", loginRec.Code, http.StatusTemporaryRedirect)
+	}
+	var stateCookie *http.Cookie
+	for _, c := range loginRec.Result().Cookies() {
+		if c.Name == stateCookieName {
+			stateCookie = c
+		}
+	}
+	if stateCookie == nil {
+		t.Fatal("login did not set the state cookie")
+	}
+
+	// ブラウザの代わりに認可エンドポイントを開き、コールバックへのリダイレクト先を受け取る
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(loginRec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("calling authorize endpoint: %v", err)
+	}
+	resp.Body.Close()
+	callbackURL, err := resp.Location()
+	if err != nil {
+		t.Fatalf("authorize endpoint did not redirect: %v", err)
+	}
+
+	// /callback/fake: code_verifier を付けてトークンと交換し、セッションを作る
+	callbackReq := mux.SetURLVars(httptest.NewRequest("GET", callbackURL.String(), nil), map[string]string{"provider": "fake"})
+	callbackReq.AddCookie(stateCookie)
+	callbackRec := httptest.NewRecorder()
+	callbackHandler(callbackRec, callbackReq)
+	if callbackRec.Code != http.StatusSeeOther {
+		t.Fatalf("callback status = %d, want %d (body: %s)", callbackRec.Code, http.StatusSeeOther, callbackRec.Body.String())
+	}
+	if fake.exchanged != 1 {
+		t.Errorf("token endpoint accepted %d exchanges, want 1", fake.exchanged)
+	}
+
+	var sessionID string
+	for _, c := range callbackRec.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			sessionID = c.Value
+		}
+	}
+	session, err := sessionStore.Get(sessionID)
+	if err != nil {
+		t.Fatalf("session was not saved: %v", err)
+	}
+	if session.AccessToken != "access-token" || session.RefreshToken != "refresh-token" {
+		t.Errorf("session tokens = %q, %q", session.AccessToken, session.RefreshToken)
+	}
+	if sub, _ := session.IDClaims["sub"].(string); sub != "user-1" {
+		t.Errorf("ID token sub = %q, want user-1", sub)
+	}
+}
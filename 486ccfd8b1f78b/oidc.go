@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+// providerMetadata は OpenID Provider の設定 (/.well-known/openid-configuration) のうち使用する項目
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	RevocationEndpoint    string `json:"revocation_endpoint"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// oidcProvider は OpenID Connect に対応したプロバイダ (Auth0・Google・Keycloak など)
+type oidcProvider struct {
+	name       string
+	config     *oauth2.Config
+	metadata   *providerMetadata
+	jwks       *jwksCache              // 署名鍵 (ID トークンと JWT のアクセストークンの検証に使う)
+	issuers    []string                // ID トークンの iss として受け付ける値 (metadata.Issuer と別表記があれば追加する)
+	scopes     []string                // 要求するスコープ (newOIDCProvider の後に変更できる)
+	authParams []oauth2.AuthCodeOption // 認可リクエストに追加するパラメータ
+	audience   string                  // アクセストークンを JWT で受け取る API の識別子 (空なら /userinfo で検証する)
+	fallback   bool                    // JWT として検証できないトークンを /userinfo で検証するか
+	logoutURL  func(*Session) string   // end_session_endpoint がない場合のログアウト URL (Auth0 の /v2/logout など)
+}
+
+// newOIDCProvider は issuer の Discovery からエンドポイントを取得してプロバイダを作る。
+// クライアントの設定は <prefix>_CLIENT_ID・<prefix>_CLIENT_SECRET・<prefix>_CALLBACK_URL・<prefix>_SCOPES (スペース区切り) から読み込む。
+func newOIDCProvider(name, issuer, prefix string) *oidcProvider {
+	metadata, err := discoverProvider(issuer)
+	if err != nil {
+		log.Fatalf("Error loading OpenID configuration for %s: %v", name, err)
+	}
+	p := newOIDCProviderWithMetadata(name, metadata, prefix)
+	if scopes := os.Getenv(prefix + "_SCOPES"); scopes != "" {
+		p.scopes = strings.Fields(scopes)
+	}
+	return p
+}
+
+// newOIDCProviderWithMetadata は取得済みのエンドポイントでプロバイダを作り、署名鍵 (JWKS) の取得を開始する
+func newOIDCProviderWithMetadata(name string, metadata *providerMetadata, prefix string) *oidcProvider {
+	p := &oidcProvider{
+		name:     name,
+		metadata: metadata,
+		issuers:  []string{metadata.Issuer},
+		// offline_access を要求するとリフレッシュトークンが発行され、アクセストークンを更新できる
+		scopes: []string{"openid", "profile", "email", "offline_access"},
+		config: &oauth2.Config{
+			ClientID:     os.Getenv(prefix + "_CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "_CLIENT_SECRET"),
+			RedirectURL:  callbackURL(name, prefix),
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  metadata.AuthorizationEndpoint,
+				TokenURL: metadata.TokenEndpoint,
+			},
+		},
+	}
+	p.jwks = &jwksCache{url: metadata.JWKSURI}
+	if err := p.jwks.refresh(); err != nil {
+		// 起動時に取得できなくても、最初の検証時に再取得する
+		log.Printf("Failed to fetch JWKS for %s: %v", name, err)
+	}
+	go p.jwks.refreshLoop(jwksRefreshInterval)
+	return p
+}
+
+// newAuth0Provider は AUTH0_* の環境変数から Auth0 のプロバイダを作る。
+// OIDC_ISSUER を設定すると Auth0 の代わりに任意の OpenID Provider を使える。
+// Auth0 を使う場合は Discovery に失敗しても従来の固定のエンドポイントで動かす。
+func newAuth0Provider() *oidcProvider {
+	domain := os.Getenv("AUTH0_DOMAIN")
+	issuer := os.Getenv("OIDC_ISSUER")
+	explicit := issuer != ""
+	if !explicit {
+		issuer = fmt.Sprintf("https://%s/", domain)
+	}
+
+	metadata, err := discoverProvider(issuer)
+	switch {
+	case err == nil:
+	case explicit:
+		log.Fatal("Error loading OpenID configuration: ", err)
+	default:
+		log.Printf("Failed to load OpenID configuration, using Auth0 endpoints: %v", err)
+		metadata = auth0Metadata(domain)
+	}
+	if v := os.Getenv("OIDC_END_SESSION_ENDPOINT"); v != "" {
+		metadata.EndSessionEndpoint = v
+	}
+
+	p := newOIDCProviderWithMetadata("auth0", metadata, "AUTH0")
+	p.audience = os.Getenv("AUTH0_AUDIENCE")
+	p.fallback = os.Getenv("AUTH0_USERINFO_FALLBACK") == "true"
+	if domain != "" {
+		p.logoutURL = func(*Session) string {
+			q := url.Values{
+				"client_id": {p.config.ClientID},
+				"returnTo":  {logoutReturnURL},
+			}
+			return fmt.Sprintf("https://%s/v2/logout?%s", domain, q.Encode())
+		}
+	}
+	return p
+}
+
+// discoverProvider は issuer の /.well-known/openid-configuration を取得する
+func discoverProvider(issuer string) (*providerMetadata, error) {
+	configURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := http.Get(configURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call discovery endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery endpoint returned status %d", resp.StatusCode)
+	}
+
+	var metadata providerMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenID configuration: %v", err)
+	}
+	// 別の issuer になりすました設定を使わないよう、issuer が一致することを確認する (OpenID Connect Discovery 4.3)
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("issuer mismatch: expected %q, got %q", issuer, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("OpenID configuration is missing required endpoints")
+	}
+	return &metadata, nil
+}
+
+// auth0Metadata は Auth0 の既定のエンドポイント
+func auth0Metadata(domain string) *providerMetadata {
+	return &providerMetadata{
+		Issuer:                fmt.Sprintf("https://%s/", domain),
+		AuthorizationEndpoint: fmt.Sprintf("https://%s/authorize", domain),
+		TokenEndpoint:         fmt.Sprintf("https://%s/oauth/token", domain),
+		UserinfoEndpoint:      fmt.Sprintf("https://%s/userinfo", domain),
+		JWKSURI:               fmt.Sprintf("https://%s/.well-known/jwks.json", domain),
+		RevocationEndpoint:    fmt.Sprintf("https://%s/oauth/revoke", domain),
+	}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.name
+}
+
+func (p *oidcProvider) OAuth2Config() *oauth2.Config {
+	config := *p.config
+	config.Scopes = p.scopes
+	return &config
+}
+
+// AuthCodeURL は PKCE の code_challenge (code_verifier の SHA-256) と nonce を付けた認可 URL を返す
+func (p *oidcProvider) AuthCodeURL(state, verifier, nonce string) string {
+	opts := []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce), // ID トークンに埋め込まれ、リプレイを検出できる
+	}
+	if p.audience != "" {
+		// API の audience を指定するとアクセストークンが JWT で発行される
+		opts = append(opts, oauth2.SetAuthURLParam("audience", p.audience))
+	}
+	opts = append(opts, p.authParams...)
+	return p.OAuth2Config().AuthCodeURL(state, opts...)
+}
+
+// Exchange は認可コードをトークンに交換し、ID トークンを検証する
+func (p *oidcProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*oauth2.Token, map[string]interface{}, error) {
+	token, err := p.OAuth2Config().Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to exchange token: %v", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, nil, errors.New("no ID token in token response")
+	}
+	idClaims, err := p.verifyIDToken(rawIDToken, nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, idClaims, nil
+}
+
+// verifyIDToken は ID トークンの署名・iss・aud・exp・nonce を検証し、クレームを返す (OpenID Connect Core 3.1.3.7)
+func (p *oidcProvider) verifyIDToken(rawIDToken, nonce string) (map[string]interface{}, error) {
+	parser := &jwt.Parser{ValidMethods: []string{"RS256"}}
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.jwks.key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if !p.verifyIssuer(claims) {
+		return nil, errors.New("invalid ID token: unexpected issuer")
+	}
+	if !hasAudience(claims["aud"], p.config.ClientID) {
+		return nil, errors.New("invalid ID token: unexpected audience")
+	}
+	// aud に複数の値がある場合は azp が自分のクライアント ID であること
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, errors.New("invalid ID token: unexpected authorized party")
+	}
+	// Parse は exp がなくても通すので、必須であることをここで確認する
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("invalid ID token: missing exp")
+	}
+	if got, _ := claims["nonce"].(string); nonce == "" || got != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("invalid ID token: missing sub")
+	}
+	return claims, nil
+}
+
+// verifyIssuer は iss クレームが受け付ける issuer のいずれかと一致するかを返す
+func (p *oidcProvider) verifyIssuer(claims jwt.MapClaims) bool {
+	for _, issuer := range p.issuers {
+		if claims.VerifyIssuer(issuer, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateToken はアクセストークンを検証してユーザー情報を返す。
+// audience が設定されていれば JWT としてローカルで検証し、/userinfo は fallback が有効な場合の代替としてだけ使う。
+func (p *oidcProvider) ValidateToken(ctx context.Context, token string, expiry time.Time) (*UserInfoResponse, error) {
+	if p.audience == "" {
+		return validateOpaqueTokenCached(ctx, token, expiry, p.userInfo)
+	}
+	userInfo, err := p.validateJWT(token)
+	if err != nil && p.fallback {
+		log.Printf("JWT validation failed, falling back to /userinfo: %v", err)
+		return validateOpaqueTokenCached(ctx, token, expiry, p.userInfo)
+	}
+	return userInfo, err
+}
+
+// userInfo はプロバイダの /userinfo エンドポイントでトークンを検証する
+func (p *oidcProvider) userInfo(ctx context.Context, token string) (*UserInfoResponse, error) {
+	if p.metadata.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("%s has no userinfo endpoint", p.name)
+	}
+	return validateOpaqueToken(ctx, p.metadata.UserinfoEndpoint, token)
+}
+
+// RevokeToken はリボケーションエンドポイントでリフレッシュトークンを失効させる
+func (p *oidcProvider) RevokeToken(ctx context.Context, refreshToken string) error {
+	if p.metadata.RevocationEndpoint == "" {
+		return fmt.Errorf("%s has no revocation endpoint", p.name)
+	}
+	return revokeRefreshToken(ctx, p.metadata.RevocationEndpoint, p.config, refreshToken)
+}
+
+// LogoutURL は end_session_endpoint があれば OIDC RP-Initiated Logout の URL を返す。
+// なければ logoutURL (Auth0 の /v2/logout など)、それもなければアプリケーションに戻るだけにする。
+func (p *oidcProvider) LogoutURL(session *Session) string {
+	if p.metadata.EndSessionEndpoint != "" {
+		q := url.Values{
+			"client_id":                {p.config.ClientID},
+			"post_logout_redirect_uri": {logoutReturnURL},
+		}
+		if session != nil && session.IDToken != "" {
+			q.Set("id_token_hint", session.IDToken)
+		}
+		return p.metadata.EndSessionEndpoint + "?" + q.Encode()
+	}
+	if p.logoutURL != nil {
+		return p.logoutURL(session)
+	}
+	return logoutReturnURL
+}
+
+// applyIDTokenClaims は ID トークンのクレームをユーザーの識別情報として userInfo に反映したコピーを返す。
+// アクセストークンと ID トークンの sub が異なる場合は別のユーザーのトークンが混ざっているのでエラーにする。
+func applyIDTokenClaims(userInfo *UserInfoResponse, idClaims map[string]interface{}) (*UserInfoResponse, error) {
+	if idClaims == nil {
+		return userInfo, nil
+	}
+	if sub, _ := idClaims["sub"].(string); sub != userInfo.Sub {
+		return nil, errors.New("access token and ID token subjects differ")
+	}
+	merged := *userInfo // キャッシュされている値を書き換えないようにコピーする
+	if name, ok := idClaims["name"].(string); ok && name != "" {
+		merged.Name = name
+	}
+	if email, ok := idClaims["email"].(string); ok && email != "" {
+		merged.Email = email
+	}
+	return &merged, nil
+}
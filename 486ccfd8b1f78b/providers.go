@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// IdentityProvider はログインに使う認可サーバ (Auth0・Google・GitHub・汎用 OIDC)
+type IdentityProvider interface {
+	Name() string
+	OAuth2Config() *oauth2.Config
+	// AuthCodeURL はログイン画面の URL を返す
+	AuthCodeURL(state, verifier, nonce string) string
+	// Exchange は認可コードをトークンに交換する。ID トークンを発行するプロバイダは検証済みのクレームも返す。
+	Exchange(ctx context.Context, code, verifier, nonce string) (*oauth2.Token, map[string]interface{}, error)
+	// ValidateToken はアクセストークンを検証してユーザー情報を返す。ctx はリクエストのコンテキストで、切断されたら検証を打ち切る。
+	// expiry はトークン交換で得た有効期限 (わからなければゼロ値) で、検証結果をキャッシュする期間の上限になる。
+	ValidateToken(ctx context.Context, accessToken string, expiry time.Time) (*UserInfoResponse, error)
+	// RevokeToken はリフレッシュトークンを失効させる
+	RevokeToken(ctx context.Context, refreshToken string) error
+	// LogoutURL はプロバイダ側のセッションを終了させる URL を返す (できなければアプリケーションに戻る URL)
+	LogoutURL(session *Session) string
+}
+
+var (
+	providers       = make(map[string]IdentityProvider)
+	defaultProvider IdentityProvider // /login・/callback で使うプロバイダ (nil なら /login/{provider} だけが使える)
+)
+
+// initializeProviders は環境変数から使用するプロバイダを登録する。
+//   - Auth0: AUTH0_DOMAIN などが設定されていれば登録する
+//   - Google: GOOGLE_CLIENT_ID が設定されていれば登録する
+//   - GitHub: GITHUB_CLIENT_ID が設定されていれば登録する
+//   - 汎用 OIDC: OIDC_PROVIDERS (例: keycloak,local) の名前ごとに <NAME>_ISSUER などから登録する
+//
+// /login・/callback の既定のプロバイダは DEFAULT_PROVIDER で指定する (selectDefaultProvider)。
+func initializeProviders() {
+	if os.Getenv("AUTH0_DOMAIN") != "" || os.Getenv("OIDC_ISSUER") != "" {
+		registerProvider(newAuth0Provider())
+	}
+	if os.Getenv("GOOGLE_CLIENT_ID") != "" {
+		google := newOIDCProvider("google", "https://accounts.google.com", "GOOGLE")
+		google.issuers = append(google.issuers, "accounts.google.com") // Google の ID トークンは iss にスキームを含まないことがある
+		google.scopes = []string{"openid", "profile", "email"}
+		// Google は offline_access スコープではなく access_type=offline でリフレッシュトークンを発行する
+		google.authParams = []oauth2.AuthCodeOption{oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent")}
+		registerProvider(google)
+	}
+	if os.Getenv("GITHUB_CLIENT_ID") != "" {
+		registerProvider(newGitHubProvider())
+	}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		prefix := strings.ToUpper(name)
+		issuer := os.Getenv(prefix + "_ISSUER")
+		if issuer == "" {
+			log.Fatalf("%s_ISSUER is not set for OIDC provider %q", prefix, name)
+		}
+		registerProvider(newOIDCProvider(name, issuer, prefix))
+	}
+
+	if len(providers) == 0 {
+		log.Fatal("No identity provider is configured (set AUTH0_DOMAIN, GOOGLE_CLIENT_ID, GITHUB_CLIENT_ID or OIDC_PROVIDERS)")
+	}
+	p, err := selectDefaultProvider(os.Getenv("DEFAULT_PROVIDER"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defaultProvider = p
+	if p == nil {
+		log.Println("No default identity provider: /login requires a provider name (set DEFAULT_PROVIDER to choose one)")
+	}
+}
+
+// selectDefaultProvider は既定のプロバイダを決める。name (DEFAULT_PROVIDER) が指定されていればそのプロバイダ、
+// なければ Auth0、Auth0 もなく登録が 1 つだけならそれにする。複数あって決められなければ nil を返す
+// (名前順などで暗黙に選ぶと、/login が利用者の意図しないプロバイダに向かうため)。
+func selectDefaultProvider(name string) (IdentityProvider, error) {
+	if name != "" {
+		p, ok := providers[name]
+		if !ok {
+			return nil, fmt.Errorf("DEFAULT_PROVIDER %q is not a registered identity provider", name)
+		}
+		return p, nil
+	}
+	if p, ok := providers["auth0"]; ok {
+		return p, nil
+	}
+	if len(providers) == 1 {
+		for _, p := range providers {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// registerProvider はプロバイダを登録する
+func registerProvider(p IdentityProvider) {
+	if _, exists := providers[p.Name()]; exists {
+		log.Fatalf("Identity provider %q is registered twice", p.Name())
+	}
+	providers[p.Name()] = p
+	log.Printf("Registered identity provider: %s", p.Name())
+}
+
+// lookupProvider は名前からプロバイダを返す。空の名前は既定のプロバイダ (/login・/callback や、プロバイダを記録する前のセッション) を表す。
+func lookupProvider(name string) (IdentityProvider, error) {
+	if name == "" {
+		if defaultProvider == nil {
+			return nil, errors.New("no default identity provider; use /login/{provider}")
+		}
+		return defaultProvider, nil
+	}
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown identity provider %q", name)
+	}
+	return p, nil
+}
+
+// callbackURL は <PREFIX>_CALLBACK_URL、未設定なら /callback/{provider} の URL を返す
+func callbackURL(name, prefix string) string {
+	if u := os.Getenv(prefix + "_CALLBACK_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:3000/callback/" + name
+}
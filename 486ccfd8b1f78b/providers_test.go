@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useProviders はテストの間だけ登録済みのプロバイダを names の OIDC プロバイダ (エンドポイントなし) に置き換える
+func useProviders(t *testing.T, names ...string) {
+	t.Helper()
+	savedProviders, savedDefault := providers, defaultProvider
+	t.Cleanup(func() { providers, defaultProvider = savedProviders, savedDefault })
+	providers = make(map[string]IdentityProvider)
+	defaultProvider = nil
+	for _, name := range names {
+		providers[name] = &oidcProvider{name: name}
+	}
+}
+
+func TestSelectDefaultProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers []string
+		env       string // DEFAULT_PROVIDER
+		want      string // 空なら既定のプロバイダなし
+		wantErr   string
+	}{
+		{"explicit", []string{"auth0", "github", "google"}, "github", "github", ""},
+		{"auth0 when not set", []string{"auth0", "github"}, "", "auth0", ""},
+		{"single provider", []string{"keycloak"}, "", "keycloak", ""},
+		// 名前順などで暗黙に選ばない
+		{"ambiguous", []string{"github", "google"}, "", "", ""},
+		{"unknown", []string{"github", "google"}, "gitlab", "", `DEFAULT_PROVIDER "gitlab" is not a registered identity provider`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProviders(t, tt.providers...)
+			p, err := selectDefaultProvider(tt.env)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if p != nil {
+				got = p.Name()
+			}
+			if got != tt.want {
+				t.Errorf("default provider = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginWithoutDefaultProvider(t *testing.T) {
+	useTestStateKeys(t)
+	useProviders(t, "github", "google")
+
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest("GET", "/login", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "/login/{provider}") {
+		t.Errorf("GET /login = %d %q, want 404 pointing to /login/{provider}", rec.Code, rec.Body.String())
+	}
+
+	// ログアウトは既定のプロバイダがなくてもアプリケーションに戻る
+	initializeLogout()
+	rec = httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest("POST", "/logout", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != logoutReturnURL {
+		t.Errorf("POST /logout = %d to %q, want %d to %q", rec.Code, rec.Header().Get("Location"), http.StatusSeeOther, logoutReturnURL)
+	}
+}
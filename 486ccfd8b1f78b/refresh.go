@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+// refreshLeeway はアクセストークンの有効期限のどれだけ前から更新するか
+const refreshLeeway = time.Minute
+
+// refreshGroup は同じセッションの更新を 1 回にまとめる。
+// リフレッシュトークンのローテーションでは古いトークンの再利用が不正利用とみなされるので、同時に更新してはいけない。
+var refreshGroup singleflight.Group
+
+// errRefreshTokenRejected は認可サーバがリフレッシュトークンを拒否した (invalid_grant) ことを表す。
+// 失効やローテーション済みのトークンの再利用が原因なので、何度やり直しても更新できない。
+var errRefreshTokenRejected = errors.New("refresh token was rejected")
+
+// needsRefresh はアクセストークンの期限が近く、リフレッシュトークンで更新できるかを返す
+func needsRefresh(session *Session) bool {
+	if session.RefreshToken == "" || session.TokenExpiry.IsZero() {
+		return false
+	}
+	return time.Until(session.TokenExpiry) < refreshLeeway
+}
+
+// refreshSession はリフレッシュトークンで新しいトークンを取得し、セッションを更新して保存する。
+// 認可サーバが新しいリフレッシュトークンを返した場合は保存しているものを置き換える (ローテーション)。
+func refreshSession(ctx context.Context, session *Session) (*Session, error) {
+	// 呼び出し元のリクエストが切断されても、共有している他のリクエストのために更新は最後まで行う
+	ctx = context.WithoutCancel(ctx)
+	v, err, _ := refreshGroup.Do(session.ID, func() (interface{}, error) {
+		// 他のリクエストが直前に更新していれば、それを使う
+		current, err := sessionStore.Get(session.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !needsRefresh(current) {
+			return current, nil
+		}
+
+		p, err := lookupProvider(current.Provider)
+		if err != nil {
+			return nil, err
+		}
+		// アクセストークンを空にして渡すと TokenSource は必ずリフレッシュトークンで更新する
+		token, err := p.OAuth2Config().TokenSource(ctx, &oauth2.Token{RefreshToken: current.RefreshToken}).Token()
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			// セッションを残すと以降のリクエストのたびにトークンエンドポイントを呼んでしまうので削除する
+			if err := sessionStore.Delete(current.ID); err != nil {
+				log.Printf("Failed to delete session: %v", err)
+			}
+			return nil, fmt.Errorf("%w: %v", errRefreshTokenRejected, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh token: %v", err)
+		}
+
+		current.AccessToken = token.AccessToken
+		current.RefreshToken = token.RefreshToken // 新しいものが返らなければ TokenSource が元のトークンを入れる
+		current.TokenExpiry = token.Expiry
+		if idToken, ok := token.Extra("id_token").(string); ok {
+			current.IDToken = idToken
+		}
+		current.ExpiresAt = time.Now().Add(sessionTTL) // 利用が続いている間はセッションを延長する
+		if err := sessionStore.Save(current); err != nil {
+			return nil, fmt.Errorf("failed to save session: %v", err)
+		}
+		log.Println("Refreshed access token")
+		return current, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Session), nil
+}
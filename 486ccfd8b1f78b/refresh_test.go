@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// saveRefreshableSession は期限が近いアクセストークンと refreshToken を持つ fake のセッションを保存する
+func saveRefreshableSession(t *testing.T, refreshToken string) *Session {
+	t.Helper()
+	session, err := newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Provider = "fake"
+	session.AccessToken = "expiring-access-token"
+	session.RefreshToken = refreshToken
+	session.TokenExpiry = time.Now().Add(10 * time.Second) // refreshLeeway より短い
+	if err := sessionStore.Save(session); err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+// serveProtected は session のクッキーを付けて validateTokenMiddleware を通したリクエストを送る
+func serveProtected(session *Session) (*httptest.ResponseRecorder, bool) {
+	called := false
+	handler := validateTokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.ID})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, called
+}
+
+func sessionCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSilentRenewalRotatesRefreshToken(t *testing.T) {
+	fake := newFakeAuthServer(t, "test-client")
+	p := useFakeProvider(t, fake)
+	p.audience = fakeAudience // リフレッシュで発行されるアクセストークンは JWT として検証する
+	fake.refreshTokens["refresh-token"] = true
+	session := saveRefreshableSession(t, "refresh-token")
+
+	rec, called := serveProtected(session)
+	if !called {
+		t.Fatalf("handler was not called: status %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	if c := sessionCookie(rec); c == nil || c.Value != session.ID || c.MaxAge <= 0 {
+		t.Errorf("session cookie after renewal = %v, want it to be re-issued for %s", c, session.ID)
+	}
+
+	stored, err := sessionStore.Get(session.ID)
+	if err != nil {
+		t.Fatalf("session after renewal: %v", err)
+	}
+	if stored.RefreshToken != "refresh-token-1" {
+		t.Errorf("stored refresh token = %q, want the rotated refresh-token-1", stored.RefreshToken)
+	}
+	if stored.AccessToken == session.AccessToken || time.Until(stored.TokenExpiry) < 30*time.Minute {
+		t.Errorf("access token was not renewed: %q (expires %v)", stored.AccessToken, stored.TokenExpiry)
+	}
+
+	// 更新後は期限まで余裕があるので、次のリクエストではトークンエンドポイントを呼ばない
+	if _, called := serveProtected(session); !called {
+		t.Error("handler was not called with the renewed session")
+	}
+	if fake.refreshes != 1 {
+		t.Errorf("token endpoint received %d refresh requests, want 1", fake.refreshes)
+	}
+	// ローテーション前のリフレッシュトークンはもう使えない
+	if fake.refreshTokens["refresh-token"] {
+		t.Error("the old refresh token is still accepted after rotation")
+	}
+}
+
+func TestRefreshRejectedDeletesSession(t *testing.T) {
+	fake := newFakeAuthServer(t, "test-client")
+	useFakeProvider(t, fake)
+	session := saveRefreshableSession(t, "revoked-refresh-token") // fake が発行していない (失効した) トークン
+
+	rec, called := serveProtected(session)
+	if called || rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d (handler called: %v), want %d", rec.Code, called, http.StatusUnauthorized)
+	}
+	if c := sessionCookie(rec); c == nil || c.MaxAge >= 0 {
+		t.Errorf("session cookie = %v, want it to be cleared", c)
+	}
+	if _, err := sessionStore.Get(session.ID); !errors.Is(err, errSessionNotFound) {
+		t.Errorf("session after invalid_grant: %v, want errSessionNotFound", err)
+	}
+
+	// セッションは削除済みなので、次のリクエストでトークンエンドポイントを呼び直さない
+	// (oauth2 はクライアント認証の方式を自動判定するため、拒否されたリクエストは 2 回送られることがある)
+	refreshes := fake.refreshes
+	if rec, _ := serveProtected(session); rec.Code != http.StatusUnauthorized {
+		t.Errorf("second request status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if fake.refreshes != refreshes {
+		t.Errorf("token endpoint received %d more refresh requests after the session was deleted", fake.refreshes-refreshes)
+	}
+}
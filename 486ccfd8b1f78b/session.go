@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName    = "session_id"     // セッション ID を保存するクッキー名
+	defaultSessionTTL    = time.Hour        // セッションの有効期限の既定値 (SESSION_TTL)
+	sessionSweepInterval = 10 * time.Minute // 期限切れセッションを削除する間隔
+)
+
+// errSessionNotFound はセッションが存在しない (または期限切れの) 場合のエラー
+var errSessionNotFound = errors.New("session not found")
+
+var (
+	sessionStore SessionStore
+	sessionTTL   = defaultSessionTTL
+)
+
+// Session はサーバ側に保存するログイン中のユーザーのトークン。クッキーにはセッション ID だけを入れる。
+type Session struct {
+	ID           string                 `json:"id"`
+	Provider     string                 `json:"provider,omitempty"` // ログインに使ったプロバイダ名
+	AccessToken  string                 `json:"access_token"`
+	RefreshToken string                 `json:"refresh_token,omitempty"`
+	IDToken      string                 `json:"id_token,omitempty"`
+	IDClaims     map[string]interface{} `json:"id_claims,omitempty"` // ログイン時に検証した ID トークンのクレーム
+	TokenExpiry  time.Time              `json:"token_expiry"`        // アクセストークンの有効期限
+	ExpiresAt    time.Time              `json:"expires_at"`          // セッション自体の有効期限
+}
+
+// expired はセッションの有効期限が切れているかを返す
+func (s *Session) expired(now time.Time) bool {
+	return now.After(s.ExpiresAt)
+}
+
+// SessionStore はセッションの保存先
+type SessionStore interface {
+	// Get は有効期限内のセッションを返す。なければ errSessionNotFound を返す。
+	Get(id string) (*Session, error)
+	Save(session *Session) error
+	Delete(id string) error
+	// DeleteExpired は now の時点で期限切れのセッションを削除し、削除した数を返す
+	DeleteExpired(now time.Time) (int, error)
+}
+
+// initializeSessionStore は環境変数 SESSION_STORE (memory または file)・SESSION_DIR・SESSION_TTL から
+// セッションストアを作り、期限切れセッションの定期削除を開始する
+func initializeSessionStore() {
+	if v := os.Getenv("SESSION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatal("Invalid SESSION_TTL: ", err)
+		}
+		sessionTTL = d
+	}
+
+	switch kind := os.Getenv("SESSION_STORE"); kind {
+	case "", "memory":
+		sessionStore = newMemorySessionStore()
+	case "file":
+		dir := os.Getenv("SESSION_DIR")
+		if dir == "" {
+			dir = "sessions"
+		}
+		store, err := newFileSessionStore(dir)
+		if err != nil {
+			log.Fatal("Error creating session store: ", err)
+		}
+		sessionStore = store
+	default:
+		log.Fatalf("Unknown SESSION_STORE %q (memory or file)", kind)
+	}
+	go sweepSessions(sessionStore, sessionSweepInterval)
+}
+
+// sweepSessions は interval ごとに期限切れのセッションを削除する
+func sweepSessions(store SessionStore, interval time.Duration) {
+	for range time.Tick(interval) {
+		n, err := store.DeleteExpired(time.Now())
+		if err != nil {
+			log.Printf("Failed to delete expired sessions: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Deleted %d expired sessions", n)
+		}
+	}
+}
+
+// newSession はランダムな ID のセッションを作る (保存はしない)
+func newSession() (*Session, error) {
+	id, err := randomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %v", err)
+	}
+	return &Session{ID: id, ExpiresAt: time.Now().Add(sessionTTL)}, nil
+}
+
+// setSessionCookie はセッション ID を HttpOnly クッキーに保存する
+func setSessionCookie(w http.ResponseWriter, session *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.ID,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false, // ローカル環境ではfalse、本番環境ではtrue
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clearSessionCookie はセッション ID のクッキーを削除する
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+}
+
+// memorySessionStore はプロセス内のマップに保存するセッションストア (再起動するとログアウトされる)
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*Session)}
+}
+
+func (m *memorySessionStore) Get(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || session.expired(time.Now()) {
+		return nil, errSessionNotFound
+	}
+	copied := *session // 呼び出し側の変更は Save するまで反映しない
+	return &copied, nil
+}
+
+func (m *memorySessionStore) Save(session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *session
+	m.sessions[session.ID] = &copied
+	return nil
+}
+
+func (m *memorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionStore) DeleteExpired(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, session := range m.sessions {
+		if session.expired(now) {
+			delete(m.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fileSessionStore はセッションごとに dir/<ID>.json へ保存するセッションストア (再起動してもログインが続く)
+type fileSessionStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func newFileSessionStore(dir string) (*fileSessionStore, error) {
+	// トークンを含むので所有者以外から読めないようにする
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileSessionStore{dir: dir}, nil
+}
+
+// path はセッション ID に対応するファイルのパスを返す。
+// ID はクッキーから来るので、base64url 以外の文字 (パス区切りなど) を含むものは拒否する。
+func (f *fileSessionStore) path(id string) (string, error) {
+	if _, err := base64.RawURLEncoding.DecodeString(id); err != nil || id == "" {
+		return "", errSessionNotFound
+	}
+	return filepath.Join(f.dir, id+".json"), nil
+}
+
+func (f *fileSessionStore) Get(id string) (*Session, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session, err := readSessionFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.expired(time.Now()) {
+		return nil, errSessionNotFound
+	}
+	return session, nil
+}
+
+func (f *fileSessionStore) Save(session *Session) error {
+	path, err := f.path(session.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// 書き込み途中のファイルを読まないよう、一時ファイルに書いてから置き換える
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f *fileSessionStore) Delete(id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *fileSessionStore) DeleteExpired(now time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(f.dir, entry.Name())
+		session, err := readSessionFile(path)
+		if err != nil {
+			log.Printf("Skipping session file %s: %v", path, err)
+			continue
+		}
+		if session.expired(now) {
+			if err := os.Remove(path); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// readSessionFile はセッションのファイルを読み込む
+func readSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %v", err)
+	}
+	return &session, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// sessionStores はテスト対象のセッションストアを作る関数 (ファイルはテストごとの一時ディレクトリに保存する)
+var sessionStores = map[string]func(t *testing.T) SessionStore{
+	"memory": func(t *testing.T) SessionStore { return newMemorySessionStore() },
+	"file": func(t *testing.T) SessionStore {
+		store, err := newFileSessionStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	},
+}
+
+// testSession は expiresIn 後に期限が切れるセッションを作る
+func testSession(t *testing.T, expiresIn time.Duration) *Session {
+	t.Helper()
+	session, err := newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Provider = "fake"
+	session.AccessToken = "access-token"
+	session.RefreshToken = "refresh-token"
+	session.TokenExpiry = time.Now().Add(time.Hour).Truncate(time.Second)
+	session.ExpiresAt = time.Now().Add(expiresIn)
+	return session
+}
+
+func TestSessionStoreRoundTrip(t *testing.T) {
+	for name, newStore := range sessionStores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			session := testSession(t, time.Hour)
+			if err := store.Save(session); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			got, err := store.Get(session.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got.Provider != "fake" || got.AccessToken != "access-token" || got.RefreshToken != "refresh-token" || !got.TokenExpiry.Equal(session.TokenExpiry) {
+				t.Errorf("Get = %+v, want %+v", got, session)
+			}
+
+			// 取得したセッションを変更しても Save するまでは保存されているものは変わらない
+			got.AccessToken = "changed"
+			if again, _ := store.Get(session.ID); again.AccessToken != "access-token" {
+				t.Errorf("AccessToken = %q after changing the returned session without Save", again.AccessToken)
+			}
+			if err := store.Save(got); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if again, _ := store.Get(session.ID); again.AccessToken != "changed" {
+				t.Errorf("AccessToken = %q after Save, want changed", again.AccessToken)
+			}
+
+			if err := store.Delete(session.ID); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := store.Get(session.ID); !errors.Is(err, errSessionNotFound) {
+				t.Errorf("Get after Delete: %v, want errSessionNotFound", err)
+			}
+			// 存在しないセッションの削除はエラーにしない (ログアウトを繰り返しても失敗しない)
+			if err := store.Delete(session.ID); err != nil {
+				t.Errorf("Delete of a missing session: %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionStoreRejectsExpired(t *testing.T) {
+	for name, newStore := range sessionStores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			session := testSession(t, -time.Second)
+			if err := store.Save(session); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if _, err := store.Get(session.ID); !errors.Is(err, errSessionNotFound) {
+				t.Errorf("Get of an expired session: %v, want errSessionNotFound", err)
+			}
+		})
+	}
+}
+
+func TestSessionStoreDeleteExpired(t *testing.T) {
+	for name, newStore := range sessionStores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			live := testSession(t, time.Hour)
+			expired := []*Session{testSession(t, -time.Minute), testSession(t, time.Minute)}
+			for _, s := range append(expired, live) {
+				if err := store.Save(s); err != nil {
+					t.Fatalf("Save: %v", err)
+				}
+			}
+
+			// 2 分後には 2 件とも期限切れになっている
+			n, err := store.DeleteExpired(time.Now().Add(2 * time.Minute))
+			if err != nil {
+				t.Fatalf("DeleteExpired: %v", err)
+			}
+			if n != 2 {
+				t.Errorf("DeleteExpired removed %d sessions, want 2", n)
+			}
+			if _, err := store.Get(live.ID); err != nil {
+				t.Errorf("Get of a live session after DeleteExpired: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileSessionStoreDeleteExpiredRemovesFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newFileSessionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, expired := testSession(t, time.Hour), testSession(t, -time.Minute)
+	for _, s := range []*Session{live, expired} {
+		if err := store.Save(s); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	// 読めないファイルや .json 以外のファイルは削除せずに残す
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := store.DeleteExpired(time.Now()); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v, want 1", n, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"README", "broken.json", live.ID + ".json"}
+	sort.Strings(want)
+	if len(names) != len(want) {
+		t.Fatalf("files after DeleteExpired = %q, want %q", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("files after DeleteExpired = %q, want %q", names, want)
+			break
+		}
+	}
+}
+
+func TestFileSessionStoreRejectsInvalidIDs(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "sessions")
+	store, err := newFileSessionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// セッションディレクトリの外に、読まれたり消されたりしてはいけないファイルを置く
+	secret := filepath.Join(root, "outside.json")
+	data := []byte(`{"id":"../outside","access_token":"secret","expires_at":"2999-01-01T00:00:00Z"}`)
+	if err := os.WriteFile(secret, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"", "../outside", "..%2Foutside", "a/b", `a\b`, "abc=", "not base64!", "a"} {
+		if _, err := store.Get(id); !errors.Is(err, errSessionNotFound) {
+			t.Errorf("Get(%q): %v, want errSessionNotFound", id, err)
+		}
+		if err := store.Save(&Session{ID: id, ExpiresAt: time.Now().Add(time.Hour)}); err == nil {
+			t.Errorf("Save(%q) succeeded", id)
+		}
+		if err := store.Delete(id); err != nil {
+			t.Errorf("Delete(%q): %v", id, err)
+		}
+	}
+	if _, err := os.Stat(secret); err != nil {
+		t.Errorf("file outside the session directory was removed: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("invalid IDs created files in the session directory: %v", entries)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	stateCookieName = "oauth_state"    // プロバイダ名・state・PKCE の code_verifier・nonce を保存するクッキー名
+	stateTTL        = 10 * time.Minute // ログイン開始からコールバックまでの有効期限
+)
+
+// stateKeys は state クッキーの署名に使う HMAC 鍵。
+// 先頭の鍵で署名し、検証はすべての鍵で行うので、鍵をローテーションしても発行済みのクッキーを受け付けられる。
+var stateKeys [][]byte
+
+// usedStates は使用済みの state (有効期限まで保持)。同じ state でのコールバックの再送を拒否する。
+var usedStates = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+// initializeStateKeys は環境変数 OAUTH_STATE_KEYS (カンマ区切り、新しい鍵を先頭) から署名鍵を読み込む。
+// 未設定の場合は起動ごとにランダムな鍵を生成する (再起動するとログイン途中の state は無効になる)。
+func initializeStateKeys() {
+	for _, key := range strings.Split(os.Getenv("OAUTH_STATE_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			stateKeys = append(stateKeys, []byte(key))
+		}
+	}
+	if len(stateKeys) > 0 {
+		return
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal("Error generating state key: ", err)
+	}
+	stateKeys = [][]byte{key}
+	log.Println("OAUTH_STATE_KEYS is not set; using a random key for this process")
+}
+
+// randomString は暗号論的に安全な乱数を base64url でエンコードした文字列を返す
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// signState はプロバイダ名・state・code_verifier・nonce・有効期限に対する HMAC-SHA256 署名を返す
+func signState(key []byte, provider, state, verifier, nonce string, expires int64) []byte {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s|%s|%s|%s|%d", provider, state, verifier, nonce, expires)
+	return mac.Sum(nil)
+}
+
+// newState はログインごとのランダムな state・PKCE の code_verifier・ID トークンの nonce を生成し、
+// ログインを始めたプロバイダ名と一緒に署名付きの HttpOnly クッキーに保存する
+func newState(w http.ResponseWriter, provider string) (state, verifier, nonce string, err error) {
+	state, err = randomString(32)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate state: %v", err)
+	}
+	nonce, err = randomString(32)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	verifier = oauth2.GenerateVerifier()
+	expires := time.Now().Add(stateTTL).Unix()
+	sig := signState(stateKeys[0], provider, state, verifier, nonce, expires)
+	http.SetCookie(w, &http.Cookie{
+		Name: stateCookieName,
+		Value: fmt.Sprintf("%s.%s.%s.%s.%d.%s", base64.RawURLEncoding.EncodeToString([]byte(provider)),
+			state, verifier, nonce, expires, base64.RawURLEncoding.EncodeToString(sig)),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false, // ローカル環境ではfalse、本番環境ではtrue
+		MaxAge:   int(stateTTL.Seconds()),
+		SameSite: http.SameSiteLaxMode, // 認可サーバからのリダイレクトでも送られるよう Lax にする
+	})
+	return state, verifier, nonce, nil
+}
+
+// verifyState はコールバックの state パラメータをクッキーの署名付き state と照合し、クッキーを削除する。
+// 署名・有効期限・一致を確認し、一度使われた state は再利用できない。
+// ログインを始めたプロバイダと異なるプロバイダのコールバックは拒否する (IdP mix-up 攻撃の対策)。
+// 成功するとトークン交換に使う code_verifier と、ID トークンの検証に使う nonce を返す。
+func verifyState(w http.ResponseWriter, r *http.Request, provider string) (verifier, nonce string, err error) {
+	clearStateCookie(w)
+
+	cookie, err := r.Cookie(stateCookieName)
+	if err != nil {
+		return "", "", errors.New("state cookie not found")
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 6 {
+		return "", "", errors.New("malformed state cookie")
+	}
+	loginProvider, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", errors.New("malformed state cookie")
+	}
+	state := parts[1]
+	verifier, nonce = parts[2], parts[3]
+	expires, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return "", "", errors.New("malformed state cookie")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[5])
+	if err != nil {
+		return "", "", errors.New("malformed state cookie")
+	}
+
+	valid := false
+	for _, key := range stateKeys {
+		if hmac.Equal(sig, signState(key, string(loginProvider), state, verifier, nonce, expires)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", "", errors.New("invalid state cookie signature")
+	}
+	if string(loginProvider) != provider {
+		return "", "", errors.New("state was issued for another provider")
+	}
+	if time.Now().Unix() > expires {
+		return "", "", errors.New("state expired")
+	}
+	if !hmac.Equal([]byte(r.URL.Query().Get("state")), []byte(state)) {
+		return "", "", errors.New("state mismatch")
+	}
+
+	usedStates.Lock()
+	defer usedStates.Unlock()
+	now := time.Now()
+	for s, exp := range usedStates.m {
+		if now.After(exp) {
+			delete(usedStates.m, s) // 有効期限切れの state はクッキー側で拒否されるので忘れてよい
+		}
+	}
+	if _, used := usedStates.m[state]; used {
+		return "", "", errors.New("state already used")
+	}
+	usedStates.m[state] = time.Unix(expires, 0)
+	return verifier, nonce, nil
+}
+
+// clearStateCookie は state クッキーを削除する
+func clearStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useTestStateKeys は現在の鍵とローテーション前の鍵をテスト用に設定する
+func useTestStateKeys(t *testing.T) {
+	t.Helper()
+	saved := stateKeys
+	stateKeys = [][]byte{[]byte("current-key"), []byte("previous-key")}
+	t.Cleanup(func() { stateKeys = saved })
+}
+
+// issueState は loginHandler と同じように newState で state クッキーを発行し、state とクッキーを返す
+func issueState(t *testing.T, provider string) (string, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	state, _, _, err := newState(rec, provider)
+	if err != nil {
+		t.Fatalf("newState: %v", err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == stateCookieName {
+			return state, c
+		}
+	}
+	t.Fatal("newState did not set the state cookie")
+	return "", nil
+}
+
+// signedStateCookie は newState と同じ形式の state クッキーを、任意の鍵・有効期限で作る
+func signedStateCookie(key []byte, provider, state string, expires time.Time) *http.Cookie {
+	verifier, nonce := "test-verifier", "test-nonce"
+	sig := signState(key, provider, state, verifier, nonce, expires.Unix())
+	return &http.Cookie{
+		Name: stateCookieName,
+		Value: fmt.Sprintf("%s.%s.%s.%s.%d.%s", base64.RawURLEncoding.EncodeToString([]byte(provider)),
+			state, verifier, nonce, expires.Unix(), base64.RawURLEncoding.EncodeToString(sig)),
+	}
+}
+
+// callbackRequest は state パラメータとクッキーを付けたコールバックのリクエストを作る
+func callbackRequest(state string, cookie *http.Cookie) *http.Request {
+	r := httptest.NewRequest("GET", "/callback/auth0?code=test-code&state="+state, nil)
+	r.AddCookie(cookie)
+	return r
+}
+
+// verifyStateError は verifyState を呼び出し、エラーメッセージ (成功なら空) を返す
+func verifyStateError(r *http.Request, provider string) string {
+	if _, _, err := verifyState(httptest.NewRecorder(), r, provider); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestVerifyStateAccepted(t *testing.T) {
+	useTestStateKeys(t)
+	state, cookie := issueState(t, "auth0")
+
+	rec := httptest.NewRecorder()
+	verifier, nonce, err := verifyState(rec, callbackRequest(state, cookie), "auth0")
+	if err != nil {
+		t.Fatalf("verifyState: %v", err)
+	}
+	if verifier == "" || nonce == "" {
+		t.Errorf("verifier = %q, nonce = %q; want non-empty values", verifier, nonce)
+	}
+	// 検証後は state クッキーを削除する
+	cleared := false
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == stateCookieName && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Error("verifyState did not clear the state cookie")
+	}
+}
+
+func TestVerifyStateReplay(t *testing.T) {
+	useTestStateKeys(t)
+	state, cookie := issueState(t, "auth0")
+
+	if msg := verifyStateError(callbackRequest(state, cookie), "auth0"); msg != "" {
+		t.Fatalf("first callback: %s", msg)
+	}
+	// 同じコールバックをもう一度送っても受け付けない
+	if msg := verifyStateError(callbackRequest(state, cookie), "auth0"); msg != "state already used" {
+		t.Errorf("second callback: got %q, want %q", msg, "state already used")
+	}
+}
+
+func TestVerifyStateMismatch(t *testing.T) {
+	useTestStateKeys(t)
+	_, cookie := issueState(t, "auth0")
+
+	if msg := verifyStateError(callbackRequest("attacker-state", cookie), "auth0"); msg != "state mismatch" {
+		t.Errorf("got %q, want %q", msg, "state mismatch")
+	}
+}
+
+func TestVerifyStateExpired(t *testing.T) {
+	useTestStateKeys(t)
+	cookie := signedStateCookie(stateKeys[0], "auth0", "expired-state", time.Now().Add(-time.Minute))
+
+	if msg := verifyStateError(callbackRequest("expired-state", cookie), "auth0"); msg != "state expired" {
+		t.Errorf("got %q, want %q", msg, "state expired")
+	}
+}
+
+func TestVerifyStateUnknownKey(t *testing.T) {
+	useTestStateKeys(t)
+	cookie := signedStateCookie([]byte("unknown-key"), "auth0", "forged-state", time.Now().Add(stateTTL))
+
+	if msg := verifyStateError(callbackRequest("forged-state", cookie), "auth0"); msg != "invalid state cookie signature" {
+		t.Errorf("got %q, want %q", msg, "invalid state cookie signature")
+	}
+}
+
+func TestVerifyStateRotatedKey(t *testing.T) {
+	useTestStateKeys(t)
+	// ローテーション前の鍵で署名された発行済みのクッキーも受け付ける
+	cookie := signedStateCookie(stateKeys[1], "auth0", "rotated-state", time.Now().Add(stateTTL))
+
+	if msg := verifyStateError(callbackRequest("rotated-state", cookie), "auth0"); msg != "" {
+		t.Errorf("got %q, want the state to be accepted", msg)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// lockMethods は sync.Mutex / sync.RWMutex のロック・アンロックを行うメソッド (types.Func.FullName 形式) と、
+// ロックを取るなら +1、外すなら -1
+var lockMethods = map[string]int{
+	"(*sync.Mutex).Lock":      1,
+	"(*sync.Mutex).TryLock":   1,
+	"(*sync.Mutex).Unlock":    -1,
+	"(*sync.RWMutex).Lock":    1,
+	"(*sync.RWMutex).TryLock": 1,
+	"(*sync.RWMutex).Unlock":  -1,
+	"(*sync.RWMutex).RLock":   1,
+	"(*sync.RWMutex).RUnlock": -1,
+}
+
+// runConcurrency はエントリーポイントごとに、到達できる関数内の goroutine・チャネル・ロックと、
+// ロックを取らずに書き換えているレシーバのフィールドを出力する
+//
+//	go run . concurrency -dir ./example
+func runConcurrency(args []string) error {
+	fset := flag.NewFlagSet("concurrency", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	fmt.Println("=== Concurrency map ===")
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		if ep.Kind == EntryTest {
+			continue
+		}
+		fmt.Printf("[%s] %s\n", ep.Kind, ep.Name)
+		c := &concurrencySummary{}
+		for _, fn := range sortedFuncs(entryReachableFuncs(ep, pkgMap)) {
+			if fn.Node.Body != nil {
+				c.collect(fn)
+			}
+		}
+		printConcurrencySection("goroutines", c.Goroutines)
+		printConcurrencySection("channels", c.Channels)
+		printConcurrencySection("locks", c.Locks)
+		printConcurrencySection("unguarded field writes", c.UnguardedWrites)
+	}
+	return nil
+}
+
+// concurrencySummary は 1 つのエントリーポイントから到達できる並行処理の要素
+type concurrencySummary struct {
+	Goroutines      []string // go 文
+	Channels        []string // make(chan) / 送信 / 受信 / close
+	Locks           []string // Lock / Unlock など
+	UnguardedWrites []string // ロックを取らずに行っているレシーバのフィールドへの書き込み
+}
+
+// collect は fn の本体から並行処理の要素を集める
+func (c *concurrencySummary) collect(fn *FunctionDefinition) {
+	typesInfo := fn.Package.TypesInfo
+	fset := fn.Package.Fset
+	name := funcDeclName(fn.Node)
+	at := func(pos token.Pos, format string, args ...interface{}) string {
+		return fmt.Sprintf("%s: %s: %s", fset.Position(pos), name, fmt.Sprintf(format, args...))
+	}
+
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GoStmt:
+			label := callLabel(n.Call, typesInfo)
+			if _, ok := n.Call.Fun.(*ast.FuncLit); ok {
+				label = "func literal"
+			}
+			c.Goroutines = append(c.Goroutines, at(n.Pos(), "go %s", label))
+		case *ast.SendStmt:
+			c.Channels = append(c.Channels, at(n.Pos(), "send to %s", types.ExprString(n.Chan)))
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				c.Channels = append(c.Channels, at(n.Pos(), "receive from %s", types.ExprString(n.X)))
+			}
+		case *ast.RangeStmt:
+			if tv, ok := typesInfo.Types[n.X]; ok {
+				if _, isChan := tv.Type.Underlying().(*types.Chan); isChan {
+					c.Channels = append(c.Channels, at(n.Pos(), "range over %s", types.ExprString(n.X)))
+				}
+			}
+		case *ast.CallExpr:
+			if b, ok := typesInfo.Uses[getCallIdent(n)].(*types.Builtin); ok && len(n.Args) > 0 {
+				switch b.Name() {
+				case "make":
+					if tv, ok := typesInfo.Types[n.Args[0]]; ok {
+						if _, isChan := tv.Type.Underlying().(*types.Chan); isChan {
+							c.Channels = append(c.Channels, at(n.Pos(), "make(%s)", types.ExprString(n.Args[0])))
+						}
+					}
+				case "close":
+					c.Channels = append(c.Channels, at(n.Pos(), "close(%s)", types.ExprString(n.Args[0])))
+				}
+			}
+			if obj, ok := typesInfo.Uses[getCallIdent(n)].(*types.Func); ok {
+				if _, isLock := lockMethods[obj.FullName()]; isLock {
+					c.Locks = append(c.Locks, at(n.Pos(), "%s", types.ExprString(n.Fun)))
+				}
+			}
+		}
+		return true
+	})
+
+	for _, w := range unguardedFieldWrites(fn) {
+		c.UnguardedWrites = append(c.UnguardedWrites, at(w.pos, "writes %s without holding a lock", w.field))
+	}
+}
+
+// fieldWrite はレシーバのフィールドへの書き込み
+type fieldWrite struct {
+	pos   token.Pos
+	field string // 例: s.count
+}
+
+// unguardedFieldWrites はポインタレシーバを持つメソッドで、ロックを保持していない位置で
+// レシーバのフィールドへ書き込んでいる箇所を返す。
+// ロックの保持は関数内の Lock / Unlock の出現順で近似する (defer した Unlock は関数の最後まで保持とみなす)。
+func unguardedFieldWrites(fn *FunctionDefinition) []fieldWrite {
+	typesInfo := fn.Package.TypesInfo
+	if fn.Node.Recv == nil || len(fn.Node.Recv.List) == 0 || len(fn.Node.Recv.List[0].Names) == 0 {
+		return nil
+	}
+	if _, ok := fn.Node.Recv.List[0].Type.(*ast.StarExpr); !ok {
+		return nil // 値レシーバへの書き込みはコピーに対するものなので共有されない
+	}
+	recv := typesInfo.Defs[fn.Node.Recv.List[0].Names[0]]
+	if recv == nil {
+		return nil
+	}
+
+	// ロック操作の位置 (defer された Unlock は除く)
+	type lockEvent struct {
+		pos   token.Pos
+		delta int
+	}
+	var events []lockEvent
+	deferred := make(map[*ast.CallExpr]bool)
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DeferStmt:
+			deferred[n.Call] = true
+		case *ast.CallExpr:
+			if obj, ok := typesInfo.Uses[getCallIdent(n)].(*types.Func); ok {
+				if delta, isLock := lockMethods[obj.FullName()]; isLock && !(delta < 0 && deferred[n]) {
+					events = append(events, lockEvent{pos: n.Pos(), delta: delta})
+				}
+			}
+		}
+		return true
+	})
+	sort.Slice(events, func(i, j int) bool { return events[i].pos < events[j].pos })
+	held := func(pos token.Pos) bool {
+		count := 0
+		for _, ev := range events {
+			if ev.pos > pos {
+				break
+			}
+			count += ev.delta
+		}
+		return count > 0
+	}
+
+	var writes []fieldWrite
+	check := func(lhs ast.Expr) {
+		field := receiverField(lhs, recv, typesInfo)
+		if field == "" || held(lhs.Pos()) {
+			return
+		}
+		writes = append(writes, fieldWrite{pos: lhs.Pos(), field: field})
+	}
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				check(lhs)
+			}
+		case *ast.IncDecStmt:
+			check(n.X)
+		}
+		return true
+	})
+	return writes
+}
+
+// receiverField は書き込み先の式 (s.count / s.m[k] / s.a.b) がレシーバ recv のフィールドであれば
+// "s.count" のような表示名を返す。sync.Mutex などの同期用の型のフィールドは対象外。
+func receiverField(expr ast.Expr, recv types.Object, typesInfo *types.Info) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.IndexExpr:
+			expr = e.X
+			continue
+		case *ast.SelectorExpr:
+			if ident, ok := e.X.(*ast.Ident); ok && typesInfo.Uses[ident] == recv {
+				sel := typesInfo.Selections[e]
+				if sel == nil || sel.Kind() != types.FieldVal {
+					return ""
+				}
+				if strings.HasPrefix(types.TypeString(sel.Type(), nil), "sync") { // sync.Mutex, sync/atomic.Int64 など
+					return ""
+				}
+				return types.ExprString(e)
+			}
+			expr = e.X
+			continue
+		}
+		return ""
+	}
+}
+
+// printConcurrencySection は 1 種類の要素の一覧を出力する
+func printConcurrencySection(title string, items []string) {
+	if len(items) == 0 {
+		fmt.Printf("  %s: (none)\n", title)
+		return
+	}
+	fmt.Printf("  %s:\n", title)
+	for _, item := range items {
+		fmt.Printf("    %s\n", item)
+	}
+}
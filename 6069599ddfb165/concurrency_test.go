@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestConcurrencyExample(t *testing.T) {
+	out := runOutput(t, "concurrency")
+	assertContains(t, entrySection(t, out, "[grpc] ExampleServer.Culc"),
+		"  goroutines: (none)\n  channels: (none)\n  locks: (none)\n  unguarded field writes: (none)\n")
+}
+
+func TestConcurrencyFixture(t *testing.T) {
+	out := runOutput(t, "concurrency", "-dir", fixtureDir(t, "concurrency"))
+
+	stats := entrySection(t, out, "[http] /stats statsHandler")
+	assertContains(t, stats,
+		"main.go:37:2: statsHandler: go sum",
+		"main.go:38:2: statsHandler: go func literal",
+		"main.go:36:13: statsHandler: make(chan int)",
+		"main.go:39:3: statsHandler: send to results",
+		"main.go:40:3: statsHandler: close(results)",
+		"main.go:44:18: statsHandler: receive from results",
+		// goroutine として起動した関数の中も到達可能として調べる
+		"main.go:48:2: sum: range over results",
+		"main.go:21:2: counter.inc: c.mu.Lock",
+		"main.go:22:8: counter.inc: c.mu.Unlock",
+		// defer した Unlock まではロックを保持しているとみなし、atomic の型は書き込みとして数えない
+		"  unguarded field writes: (none)\n",
+	)
+	assertNotContains(t, stats, "counter.reset")
+
+	reset := entrySection(t, out, "[http] /reset resetHandler")
+	assertContains(t, reset,
+		"  goroutines: (none)\n  channels: (none)\n",
+		"main.go:31:2: counter.reset: writes c.last without holding a lock",
+	)
+	assertNotContains(t, reset, "writes c.hits")
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ioFuncs は I/O (ネットワーク・ファイル・DB・待機) を行う代表的な関数・メソッド (types.Func.FullName 形式)。
+// これらを呼ぶ関数がリクエストの context を受け取っていないと、キャンセルやタイムアウトが伝わらない。
+// context を受け取る版がある関数は contextVariants に置く。
+var ioFuncs = map[string]bool{
+	"(*net/http.Client).Do":                       true,
+	"(*database/sql.Tx).Commit":                   true,
+	"os.ReadFile":                                 true,
+	"os.WriteFile":                                true,
+	"os.Open":                                     true,
+	"os.OpenFile":                                 true,
+	"os.Create":                                   true,
+	"(*os/exec.Cmd).Run":                          true,
+	"(*os/exec.Cmd).Output":                       true,
+	"(*os/exec.Cmd).CombinedOutput":               true,
+	"google.golang.org/grpc.Dial":                 true,
+	"google.golang.org/grpc.NewClient":            true,
+	"(*google.golang.org/grpc.ClientConn).Invoke": true,
+	"(google.golang.org/grpc.ClientConnInterface).Invoke": true,
+	"time.Sleep": true,
+}
+
+// contextVariants は context を受け取らない I/O 関数と、代わりに使うべき context を受け取る版。
+// 呼び出し元が context を受け取っていても、これらを使うとリクエストのキャンセルが伝わらない。
+var contextVariants = map[string]string{
+	"net.Dial":                      "(*net.Dialer).DialContext",
+	"net.DialTimeout":               "(*net.Dialer).DialContext",
+	"(*net.Dialer).Dial":            "(*net.Dialer).DialContext",
+	"net/http.Get":                  "http.NewRequestWithContext and (*http.Client).Do",
+	"net/http.Post":                 "http.NewRequestWithContext and (*http.Client).Do",
+	"net/http.Head":                 "http.NewRequestWithContext and (*http.Client).Do",
+	"net/http.PostForm":             "http.NewRequestWithContext and (*http.Client).Do",
+	"(*net/http.Client).Get":        "http.NewRequestWithContext and (*http.Client).Do",
+	"(*net/http.Client).Post":       "http.NewRequestWithContext and (*http.Client).Do",
+	"(*net/http.Client).Head":       "http.NewRequestWithContext and (*http.Client).Do",
+	"(*net/http.Client).PostForm":   "http.NewRequestWithContext and (*http.Client).Do",
+	"(*database/sql.DB).Query":      "(*sql.DB).QueryContext",
+	"(*database/sql.DB).QueryRow":   "(*sql.DB).QueryRowContext",
+	"(*database/sql.DB).Exec":       "(*sql.DB).ExecContext",
+	"(*database/sql.DB).Begin":      "(*sql.DB).BeginTx",
+	"(*database/sql.DB).Ping":       "(*sql.DB).PingContext",
+	"(*database/sql.DB).Prepare":    "(*sql.DB).PrepareContext",
+	"(*database/sql.Tx).Query":      "(*sql.Tx).QueryContext",
+	"(*database/sql.Tx).QueryRow":   "(*sql.Tx).QueryRowContext",
+	"(*database/sql.Tx).Exec":       "(*sql.Tx).ExecContext",
+	"(*database/sql.Stmt).Query":    "(*sql.Stmt).QueryContext",
+	"(*database/sql.Stmt).QueryRow": "(*sql.Stmt).QueryRowContext",
+	"(*database/sql.Stmt).Exec":     "(*sql.Stmt).ExecContext",
+}
+
+// cancelFuncs は cancel 関数を返す context パッケージの関数
+var cancelFuncs = map[string]bool{
+	"WithCancel":        true,
+	"WithCancelCause":   true,
+	"WithTimeout":       true,
+	"WithTimeoutCause":  true,
+	"WithDeadline":      true,
+	"WithDeadlineCause": true,
+}
+
+// runCtxCheck は各 RPC / HTTP ハンドラから到達できる関数をたどり、context の伝播に関する問題を出力する
+//
+//	go run . ctxcheck -dir ./example
+func runCtxCheck(args []string) error {
+	fset := flag.NewFlagSet("ctxcheck", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	fmt.Println("=== Context propagation check ===")
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		if ep.Kind == EntryTest {
+			continue
+		}
+		fmt.Printf("[%s] %s\n", ep.Kind, ep.Name)
+		issues := checkContextPropagation(ep, pkgMap)
+		if len(issues) == 0 {
+			fmt.Println("  (no issues)")
+		}
+		for _, issue := range issues {
+			fmt.Printf("  WARNING: %s\n", issue)
+		}
+	}
+	return nil
+}
+
+// checkContextPropagation は ep から到達できる関数を調べ、問題点を返す。
+// main 関数はリクエストの context を持たないため、cancel 呼び出しの漏れだけを調べる。
+func checkContextPropagation(ep *EntryPoint, pkgMap map[string]*packages.Package) []string {
+	var issues []string
+	for _, fn := range sortedFuncs(entryReachableFuncs(ep, pkgMap)) {
+		if fn.Node.Body == nil {
+			continue
+		}
+		typesInfo := fn.Package.TypesInfo
+		fset := fn.Package.Fset
+		name := funcDeclName(fn.Node)
+
+		issues = append(issues, checkCancelCalls(fn)...)
+		if ep.Kind == EntryMain {
+			continue
+		}
+
+		hasCtx := receivesContext(fn)
+		if !ep.isRoot(fn) && !hasCtx && directIOCall(fn) == "" {
+			// 自身は I/O を行わないが、呼び出し先で I/O に到達する場合は context の伝播が途切れる
+			if via, io := reachedIOCall(fn, pkgMap); io != "" {
+				issues = append(issues, fmt.Sprintf("%s: %s does not receive a context but reaches I/O (%s via %s)", fset.Position(fn.Node.Pos()), name, io, via))
+			}
+		}
+		ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			obj, _ := typesInfo.ObjectOf(getCallIdent(call)).(*types.Func)
+			if obj == nil || obj.Pkg() == nil {
+				return true
+			}
+			pos := fset.Position(call.Pos())
+			switch {
+			case obj.Pkg().Path() == "context" && (obj.Name() == "Background" || obj.Name() == "TODO"):
+				issues = append(issues, fmt.Sprintf("%s: %s replaces the request context with context.%s()", pos, name, obj.Name()))
+			case contextVariants[obj.FullName()] != "":
+				issues = append(issues, fmt.Sprintf("%s: %s calls %s, which cannot be cancelled by a context; use %s", pos, name, obj.FullName(), contextVariants[obj.FullName()]))
+			case ioFuncs[obj.FullName()] && !hasCtx:
+				issues = append(issues, fmt.Sprintf("%s: %s performs I/O (%s) without receiving a context", pos, name, obj.FullName()))
+			}
+			return true
+		})
+	}
+	return issues
+}
+
+// directIOCall は fn の本体で直接呼んでいる最初の I/O 関数の名前を返す (なければ空文字列)
+func directIOCall(fn *FunctionDefinition) string {
+	typesInfo := fn.Package.TypesInfo
+	var found string
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || found != "" {
+			return found == ""
+		}
+		if obj, _ := typesInfo.ObjectOf(getCallIdent(call)).(*types.Func); obj != nil && obj.Pkg() != nil {
+			if name := obj.FullName(); ioFuncs[name] || contextVariants[name] != "" {
+				found = name
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// reachedIOCall は fn から到達できる関数のうち I/O を行う最初の関数と、その I/O 関数の名前を返す
+func reachedIOCall(fn *FunctionDefinition, pkgMap map[string]*packages.Package) (string, string) {
+	for _, callee := range sortedFuncs(reachableFuncs(fn, pkgMap)) {
+		if callee.Node.Body == nil {
+			continue
+		}
+		if io := directIOCall(callee); io != "" {
+			return funcDeclName(callee.Node), io
+		}
+	}
+	return "", ""
+}
+
+// receivesContext は関数が context.Context (または context を持つ *http.Request) を引数に取るかどうかを判定する
+func receivesContext(fn *FunctionDefinition) bool {
+	obj, ok := fn.Package.TypesInfo.Defs[fn.Node.Name].(*types.Func)
+	if !ok {
+		return false
+	}
+	params := obj.Type().(*types.Signature).Params()
+	for i := 0; i < params.Len(); i++ {
+		switch types.TypeString(params.At(i).Type(), nil) {
+		case "context.Context", "*net/http.Request":
+			return true
+		}
+	}
+	return false
+}
+
+// checkCancelCalls は context.WithCancel / WithTimeout などが返す cancel 関数について、
+// 破棄されている・一度も使われていない・ループ内で defer されている箇所を探す
+func checkCancelCalls(fn *FunctionDefinition) []string {
+	typesInfo := fn.Package.TypesInfo
+	fset := fn.Package.Fset
+	name := funcDeclName(fn.Node)
+
+	var issues []string
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+			return true
+		}
+		call, ok := assign.Rhs[0].(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		obj, _ := typesInfo.ObjectOf(getCallIdent(call)).(*types.Func)
+		if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != "context" || !cancelFuncs[obj.Name()] {
+			return true
+		}
+		pos := fset.Position(call.Pos())
+		ident, ok := assign.Lhs[1].(*ast.Ident)
+		if !ok || ident.Name == "_" {
+			issues = append(issues, fmt.Sprintf("%s: %s discards the cancel function of context.%s", pos, name, obj.Name()))
+			return true
+		}
+		cancel := typesInfo.ObjectOf(ident)
+		if uses := identUses(fn.Node.Body, cancel, typesInfo); len(uses) == 0 {
+			issues = append(issues, fmt.Sprintf("%s: %s never calls the cancel function of context.%s", pos, name, obj.Name()))
+		} else if deferPos := deferredInLoop(fn.Node.Body, cancel, typesInfo); deferPos.IsValid() {
+			issues = append(issues, fmt.Sprintf("%s: %s defers cancel inside a loop; contexts are not released until the function returns", fset.Position(deferPos), name))
+		}
+		return true
+	})
+	return issues
+}
+
+// identUses は node 内で obj を参照している識別子を返す
+func identUses(node ast.Node, obj types.Object, typesInfo *types.Info) []*ast.Ident {
+	var uses []*ast.Ident
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && typesInfo.Uses[ident] == obj {
+			uses = append(uses, ident)
+		}
+		return true
+	})
+	return uses
+}
+
+// deferredInLoop は for / range の中 (関数リテラルの外) で defer cancel() している位置を返す
+func deferredInLoop(body *ast.BlockStmt, cancel types.Object, typesInfo *types.Info) token.Pos {
+	var found token.Pos
+	var walk func(n ast.Node, inLoop bool)
+	walk = func(n ast.Node, inLoop bool) {
+		ast.Inspect(n, func(c ast.Node) bool {
+			switch c := c.(type) {
+			case *ast.FuncLit:
+				walk(c.Body, false)
+				return false
+			case *ast.ForStmt:
+				walk(c.Body, true)
+				return false
+			case *ast.RangeStmt:
+				walk(c.Body, true)
+				return false
+			case *ast.DeferStmt:
+				if ident, ok := c.Call.Fun.(*ast.Ident); ok && inLoop && typesInfo.Uses[ident] == cancel && !found.IsValid() {
+					found = c.Pos()
+				}
+			}
+			return true
+		})
+	}
+	walk(body, false)
+	return found
+}
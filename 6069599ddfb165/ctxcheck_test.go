@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestCtxCheckExample(t *testing.T) {
+	out := runOutput(t, "ctxcheck")
+	assertContains(t, out, "[grpc] ExampleServer.Culc\n  (no issues)")
+	// I/O に到達しない context なしの呼び出し先 (Multiply, Print など) は報告しない
+	assertNotContains(t, out, "WARNING", "callees without ctx")
+}
+
+func TestCtxCheckFixture(t *testing.T) {
+	out := runOutput(t, "ctxcheck", "-dir", fixtureDir(t, "ctxcheck"))
+	assertContains(t, out,
+		"loadUsers does not receive a context but reaches I/O ((*database/sql.DB).Query via queryUsers)",
+		"queryUsers calls (*database/sql.DB).Query, which cannot be cancelled by a context; use (*sql.DB).QueryContext",
+		// 呼び出し元が context を受け取っていても、context を受け取らない版の呼び出しは報告する
+		"fetchHandler calls net/http.Get, which cannot be cancelled by a context",
+		"[http] /count countHandler\n  (no issues)",
+	)
+	assertNotContains(t, out, "label does not receive a context")
+}
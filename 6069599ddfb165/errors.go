@@ -0,0 +1,399 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// runErrors は各 RPC 実装の return 文をたどり、どの経路でどんなエラーを返すかを出力する
+//
+//	go run . errors -dir ./example
+func runErrors(args []string) error {
+	fset := flag.NewFlagSet("errors", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	a := newErrorAnalyzer(pkgMap)
+	fmt.Println("=== Error paths for RPC handlers ===")
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		if ep.Kind != EntryGRPC {
+			continue
+		}
+		fmt.Printf("[RPC] %s\n", ep.Name)
+		for _, p := range a.errorPaths(ep.Def) {
+			fmt.Printf("  %s: return %s\n", p.Pos, p.Expr)
+			fmt.Printf("    -> %s\n", p.Desc)
+			for _, w := range p.Warnings {
+				fmt.Printf("    WARNING: %s\n", w)
+			}
+		}
+	}
+	return nil
+}
+
+// エラー経路の分類
+const (
+	errKindNil        = "nil"        // nil を返す
+	errKindStatus     = "status"     // status.Error などで gRPC のステータスコード付きエラーを返す
+	errKindRaw        = "raw"        // errors.New / fmt.Errorf などのステータスを持たないエラー (codes.Unknown になる)
+	errKindPropagated = "propagated" // 呼び出し先から受け取ったエラーをそのまま返す
+	errKindUnknown    = "unknown"    // 解析できないエラー (外部パッケージの関数など)
+)
+
+// errorPath は 1 つの return 文と、そこで返るエラーの分類
+type errorPath struct {
+	Pos      token.Position
+	Expr     string   // return 文の式
+	Kinds    []string // 返りうるエラーの分類 (呼び出し先から伝播する場合は複数になりうる)
+	Desc     string   // 人が読むための説明
+	Warnings []string
+}
+
+// errorAnalyzer は return 文を解析し、呼び出し先から伝播するエラーも再帰的にたどる
+type errorAnalyzer struct {
+	pkgMap     map[string]*packages.Package
+	inProgress map[string]bool        // 再帰呼び出しで無限にたどらないよう、要約中の関数 ID を記録
+	visiting   map[assignmentKey]bool // 代入元をたどるときに循環しないよう、たどっている代入を記録
+}
+
+// assignmentKey は変数とそれへの代入文 (*ast.AssignStmt または *ast.ValueSpec) の組
+type assignmentKey struct {
+	obj  types.Object
+	stmt ast.Node
+}
+
+func newErrorAnalyzer(pkgMap map[string]*packages.Package) *errorAnalyzer {
+	return &errorAnalyzer{
+		pkgMap:     pkgMap,
+		inProgress: make(map[string]bool),
+		visiting:   make(map[assignmentKey]bool),
+	}
+}
+
+// errorPaths は fn の return 文ごとにエラーの分類を返す。
+// 最後の戻り値が error でない関数は対象外 (nil を返す)。関数リテラル内の return は含めない。
+func (a *errorAnalyzer) errorPaths(fn *FunctionDefinition) []*errorPath {
+	typesInfo := fn.Package.TypesInfo
+	obj, ok := typesInfo.Defs[fn.Node.Name].(*types.Func)
+	if !ok || fn.Node.Body == nil {
+		return nil
+	}
+	sig := obj.Type().(*types.Signature)
+	results := sig.Results()
+	if results.Len() == 0 || !isErrorType(results.At(results.Len()-1).Type()) {
+		return nil
+	}
+
+	var paths []*errorPath
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			p := &errorPath{
+				Pos:  fn.Package.Fset.Position(n.Pos()),
+				Expr: exprListString(n.Results),
+			}
+			if len(n.Results) == 0 {
+				// 名前付き戻り値の bare return は、エラーの戻り値変数への代入元をたどる
+				p.Kinds, p.Desc = a.classifyVar(results.At(results.Len()-1), n.Pos(), fn)
+			} else {
+				p.Kinds, p.Desc = a.classify(n.Results[len(n.Results)-1], n.Pos(), fn)
+			}
+			a.addWarnings(p, n, typesInfo)
+			paths = append(paths, p)
+		}
+		return true
+	})
+	return paths
+}
+
+// addWarnings は codes.Unknown になってしまう経路や nil, nil を返す経路に警告を付ける
+func (a *errorAnalyzer) addWarnings(p *errorPath, ret *ast.ReturnStmt, typesInfo *types.Info) {
+	for _, kind := range p.Kinds {
+		if kind == errKindRaw {
+			p.Warnings = append(p.Warnings, "returns a non-status error; gRPC will report it as codes.Unknown")
+			break
+		}
+	}
+	if len(ret.Results) < 2 {
+		return
+	}
+	for _, r := range ret.Results {
+		if !isNilExpr(r, typesInfo) {
+			return
+		}
+	}
+	p.Warnings = append(p.Warnings, "returns nil response with nil error")
+}
+
+// classify はエラーを表す式 expr を分類する。pos は return 文の位置 (変数の代入元を探すのに使う)。
+func (a *errorAnalyzer) classify(expr ast.Expr, pos token.Pos, fn *FunctionDefinition) ([]string, string) {
+	typesInfo := fn.Package.TypesInfo
+	expr = ast.Unparen(expr)
+	if isNilExpr(expr, typesInfo) {
+		return []string{errKindNil}, "nil error"
+	}
+
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		return a.classifyCall(e, pos, fn)
+	case *ast.Ident:
+		if obj := typesInfo.ObjectOf(e); obj != nil {
+			return a.classifyVar(obj, pos, fn)
+		}
+	}
+	return []string{errKindUnknown}, "unknown error value " + types.ExprString(expr)
+}
+
+// classifyVar はエラー変数 obj を、pos より前の最後の代入元をたどって分類する。
+// err = fmt.Errorf("...: %w", err) のような再代入では、右辺の err はその代入より前の代入元をたどる。
+func (a *errorAnalyzer) classifyVar(obj types.Object, pos token.Pos, fn *FunctionDefinition) ([]string, string) {
+	src, stmt := lastAssignment(fn.Node.Body, obj, pos, fn.Package.TypesInfo)
+	if src == nil {
+		return []string{errKindUnknown}, "unassigned error variable " + obj.Name()
+	}
+	key := assignmentKey{obj: obj, stmt: stmt}
+	if a.visiting[key] {
+		return []string{errKindUnknown}, "cyclic assignment to " + obj.Name()
+	}
+	a.visiting[key] = true
+	defer delete(a.visiting, key)
+
+	kinds, desc := a.classify(src, stmt.Pos(), fn)
+	return kinds, fmt.Sprintf("%s (assigned to %s from %s)", desc, obj.Name(), types.ExprString(src))
+}
+
+// classifyCall はエラーを生成・取得する関数呼び出しを分類する
+func (a *errorAnalyzer) classifyCall(call *ast.CallExpr, pos token.Pos, fn *FunctionDefinition) ([]string, string) {
+	typesInfo := fn.Package.TypesInfo
+	obj, _ := typesInfo.ObjectOf(getCallIdent(call)).(*types.Func)
+	if obj == nil || obj.Pkg() == nil {
+		return []string{errKindUnknown}, "unknown call " + types.ExprString(call.Fun)
+	}
+	pkgPath, name := obj.Pkg().Path(), obj.Name()
+
+	switch {
+	case pkgPath == "google.golang.org/grpc/status" || pkgPath == "google.golang.org/grpc/internal/status":
+		// status.Error(codes.X, ...) / status.Errorf(codes.X, ...) / status.New(codes.X, ...).Err()
+		// (status.Status は internal/status の型エイリアスなので、Err メソッドは internal 側になる)
+		target := call
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && name == "Err" {
+			if inner, ok := ast.Unparen(sel.X).(*ast.CallExpr); ok {
+				target = inner
+			}
+		}
+		switch targetName := getCallIdent(target).Name; {
+		case targetName == "Convert" && len(target.Args) == 1:
+			// status.Convert(err).Err() は err がステータスを持たなければ codes.Unknown になるので、err の分類を引き継ぐ
+			kinds, desc := a.classify(target.Args[0], pos, fn)
+			return kinds, "converted with status.Convert: " + desc
+		case (targetName == "Error" || targetName == "Errorf" || targetName == "New" || targetName == "Newf") && len(target.Args) > 0:
+			return []string{errKindStatus}, "status error " + types.ExprString(target.Args[0])
+		}
+		return []string{errKindStatus}, "status error (dynamic code)"
+	case pkgPath == "errors" && name == "New":
+		return []string{errKindRaw}, "raw error errors.New"
+	case pkgPath == "fmt" && name == "Errorf":
+		// %w でラップしている場合はラップ元の分類を引き継ぐ (status.FromError はラップを剥がして判定する)
+		if wrapped := wrappedErrorArg(call, typesInfo); wrapped != nil {
+			kinds, desc := a.classify(wrapped, pos, fn)
+			return kinds, "wrapped with fmt.Errorf: " + desc
+		}
+		return []string{errKindRaw}, "raw error fmt.Errorf"
+	}
+
+	// 解析対象パッケージ内の関数であれば、その関数が返すエラーを要約して伝播元として示す
+	if callee := findFuncDecl(obj, a.pkgMap); callee != nil {
+		kinds, summary := a.summarize(callee)
+		return append([]string{errKindPropagated}, kinds...), fmt.Sprintf("propagated from %s [%s]", funcDeclName(callee.Node), summary)
+	}
+	return []string{errKindUnknown}, fmt.Sprintf("propagated from external %s.%s", obj.Pkg().Name(), name)
+}
+
+// summarize は呼び出し先関数が返しうるエラーの分類と説明をまとめる
+func (a *errorAnalyzer) summarize(fn *FunctionDefinition) ([]string, string) {
+	id := functionID(fn)
+	if a.inProgress[id] {
+		return nil, "recursive"
+	}
+	a.inProgress[id] = true
+	defer delete(a.inProgress, id)
+
+	kindSet := make(map[string]bool)
+	descSet := make(map[string]bool)
+	for _, p := range a.errorPaths(fn) {
+		for _, k := range p.Kinds {
+			kindSet[k] = true
+		}
+		descSet[p.Desc] = true
+	}
+	var kinds, descs []string
+	for k := range kindSet {
+		kinds = append(kinds, k)
+	}
+	for d := range descSet {
+		descs = append(descs, d)
+	}
+	sort.Strings(kinds)
+	sort.Strings(descs)
+	return kinds, strings.Join(descs, " | ")
+}
+
+// lastAssignment は body 内で pos より前に完了した obj への最後の代入の右辺と、その代入文を返す
+// (x, err := f() のような多値代入の場合は呼び出し f() を返す)。
+// pos を含む代入文自体は対象外なので、err = fmt.Errorf("...: %w", err) の右辺の err はその前の代入に解決される。
+func lastAssignment(body *ast.BlockStmt, obj types.Object, pos token.Pos, typesInfo *types.Info) (ast.Expr, ast.Node) {
+	var src ast.Expr
+	var stmt ast.Node
+	ast.Inspect(body, func(n ast.Node) bool {
+		if n == nil || n.Pos() >= pos {
+			return false
+		}
+		var lhs []*ast.Ident
+		var rhs []ast.Expr
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.AssignStmt:
+			for _, l := range s.Lhs {
+				ident, _ := l.(*ast.Ident)
+				lhs = append(lhs, ident)
+			}
+			rhs = s.Rhs
+		case *ast.ValueSpec:
+			lhs, rhs = s.Names, s.Values
+		default:
+			return true
+		}
+		if n.End() > pos {
+			return true
+		}
+		for i, ident := range lhs {
+			if ident == nil || typesInfo.ObjectOf(ident) != obj {
+				continue
+			}
+			switch {
+			case len(rhs) == len(lhs):
+				src, stmt = rhs[i], n
+			case len(rhs) == 1:
+				src, stmt = rhs[0], n
+			}
+		}
+		return true
+	})
+	return src, stmt
+}
+
+// wrappedErrorArg は fmt.Errorf のフォーマット文字列中の最初の %w に対応する引数を返す。
+// フラグ・幅・精度 (* を含む) と %[1]w のような明示的な引数インデックスを fmt と同じように解釈する。
+func wrappedErrorArg(call *ast.CallExpr, typesInfo *types.Info) ast.Expr {
+	if len(call.Args) == 0 {
+		return nil
+	}
+	tv, ok := typesInfo.Types[call.Args[0]]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return nil
+	}
+	format := constant.StringVal(tv.Value)
+	argNum := 0 // 次に使われる引数の番号 (フォーマット文字列の次の引数が 0)
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		i, argNum = skipFormatNumber(format, i, argNum) // 幅
+		if i < len(format) && format[i] == '.' {
+			i, argNum = skipFormatNumber(format, i+1, argNum) // 精度
+		}
+		if n, next, ok := formatArgIndex(format, i); ok {
+			argNum, i = n, next
+		}
+		if i >= len(format) {
+			break
+		}
+		switch format[i] {
+		case '%':
+			continue
+		case 'w':
+			if argNum+1 < len(call.Args) {
+				return call.Args[argNum+1]
+			}
+			return nil
+		}
+		argNum++
+	}
+	return nil
+}
+
+// skipFormatNumber はフォーマット文字列の i 以降の幅・精度 ([n]* / * / 数字) を読み飛ばし、
+// 読み終えた位置と次に使われる引数の番号を返す
+func skipFormatNumber(format string, i, argNum int) (int, int) {
+	if n, next, ok := formatArgIndex(format, i); ok {
+		argNum, i = n, next
+	}
+	if i < len(format) && format[i] == '*' {
+		return i + 1, argNum + 1
+	}
+	for i < len(format) && '0' <= format[i] && format[i] <= '9' {
+		i++
+	}
+	return i, argNum
+}
+
+// formatArgIndex は i の位置にある [n] を読み、0 始まりの引数の番号と ] の次の位置を返す
+func formatArgIndex(format string, i int) (int, int, bool) {
+	if i >= len(format) || format[i] != '[' {
+		return 0, i, false
+	}
+	end := strings.IndexByte(format[i:], ']')
+	if end < 0 {
+		return 0, i, false
+	}
+	n, err := strconv.Atoi(format[i+1 : i+end])
+	if err != nil || n < 1 {
+		return 0, i, false
+	}
+	return n - 1, i + end + 1, true
+}
+
+// isErrorType は t が組み込みの error 型かどうかを判定する
+func isErrorType(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+// isNilExpr は expr が nil かどうかを判定する
+func isNilExpr(expr ast.Expr, typesInfo *types.Info) bool {
+	ident, ok := ast.Unparen(expr).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, isNil := typesInfo.ObjectOf(ident).(*types.Nil)
+	return isNil
+}
+
+// exprListString は return 文の式リストを "a, b" 形式の文字列にする
+func exprListString(exprs []ast.Expr) string {
+	s := make([]string, len(exprs))
+	for i, e := range exprs {
+		s[i] = types.ExprString(e)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestErrorsExample(t *testing.T) {
+	out := runOutput(t, "errors")
+	assertContains(t, out, "[RPC] ExampleServer.Culc", "return &example.CulcResponse{…}, nil", "-> nil error")
+	assertNotContains(t, out, "WARNING")
+}
+
+func TestErrorsFixture(t *testing.T) {
+	out := runOutput(t, "errors", "-dir", fixtureDir(t, "errors"))
+	assertContains(t, out,
+		// err = fmt.Errorf("...: %w", err) の右辺の err はその前の代入元に解決される (再帰し続けない)
+		`-> wrapped with fmt.Errorf: propagated from load [nil error | status error codes.InvalidArgument] (assigned to err from load(req.Name)) (assigned to err from fmt.Errorf("open: %w", err))`,
+		// status.Convert はステータスを持たないエラーを codes.Unknown にする
+		"-> converted with status.Convert: propagated from read [raw error errors.New]",
+		"WARNING: returns a non-status error; gRPC will report it as codes.Unknown",
+		// %[1]w は 1 番目の引数をラップする
+		`return nil, fmt.Errorf("index %[2]q: %[1]w", err, req.Name)
+    -> wrapped with fmt.Errorf: propagated from load`,
+	)
+	assertNotContains(t, out, "status error err")
+}
+
+func TestWrappedErrorArg(t *testing.T) {
+	tests := []struct {
+		format string
+		want   int // ラップされる引数の番号 (フォーマット文字列の次が 1)。-1 ならラップなし
+	}{
+		{`"open: %w"`, 1},
+		{`"%s: %w"`, 2},
+		{`"100%% %v: %w"`, 2},
+		{`"%*d %w"`, 3},
+		{`"%-8.*f %w"`, 3},
+		{`"%[2]s: %[1]w"`, 1},
+		{`"%[1]*d %w"`, 3},
+		{`"%v"`, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			expr, typesInfo := typeCheckErrorf(t, tt.format)
+			call := expr.(*ast.CallExpr)
+			got := wrappedErrorArg(call, typesInfo)
+			if tt.want < 0 {
+				if got != nil {
+					t.Errorf("got %s, want no wrapped argument", types.ExprString(got))
+				}
+				return
+			}
+			if got != call.Args[tt.want] {
+				t.Errorf("got %s, want argument %d", types.ExprString(got), tt.want)
+			}
+		})
+	}
+}
+
+// typeCheckErrorf は Errorf(format, a, b, c) の呼び出しを型検査し、フォーマット文字列を定数として持つ式を返す
+func typeCheckErrorf(t *testing.T, format string) (ast.Expr, *types.Info) {
+	t.Helper()
+	src := "package p\nfunc Errorf(format string, a ...any) error { return nil }\nvar a, b, c int\nvar _ = Errorf(" + format + ", a, b, c)\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typesInfo := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{file}, typesInfo); err != nil {
+		t.Fatal(err)
+	}
+	spec := file.Decls[2].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	return spec.Values[0], typesInfo
+}
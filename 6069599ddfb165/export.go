@@ -0,0 +1,270 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+	_ "modernc.org/sqlite" // cgo を使わない SQLite ドライバ (オフラインの環境でもそのままビルドできる)
+)
+
+// sqliteSchema は export が書き出す SQLite データベースのスキーマ。
+// 関数 ID は functionID と同じ「パッケージパス.(レシーバ型.)関数名」形式で、解析対象外の関数も同じ形式にする。
+const sqliteSchema = `
+-- 解析対象のパッケージ
+CREATE TABLE packages (
+	path   TEXT PRIMARY KEY, -- パッケージパス
+	name   TEXT NOT NULL,    -- パッケージ名
+	module TEXT              -- 所属するモジュールのパス
+);
+
+-- 解析対象のパッケージで宣言されている関数・メソッド
+CREATE TABLE functions (
+	id      TEXT PRIMARY KEY,                        -- 関数 ID (例: example.com/m/server.ExampleServer.Culc)
+	package TEXT NOT NULL REFERENCES packages(path),
+	name    TEXT NOT NULL,                           -- 表示名 (例: ExampleServer.Culc)
+	file    TEXT NOT NULL,                           -- 絶対パス
+	line    INTEGER NOT NULL,
+	col     INTEGER NOT NULL
+);
+
+-- 関数の本体 (関数リテラルを含む) から別の関数への参照
+CREATE TABLE call_edges (
+	caller TEXT NOT NULL REFERENCES functions(id),
+	callee TEXT NOT NULL, -- 呼び出し先の関数 ID (解析対象外の関数なら functions に存在しない)
+	kind   TEXT NOT NULL, -- call: 静的な呼び出し / interface: インターフェイス経由の呼び出し /
+	                      -- go: go 文での呼び出し / defer: defer 文での呼び出し / reference: 関数を値として参照
+	file   TEXT NOT NULL, -- 参照している位置 (絶対パス)
+	line   INTEGER NOT NULL,
+	col    INTEGER NOT NULL
+);
+CREATE INDEX call_edges_caller ON call_edges(caller);
+CREATE INDEX call_edges_callee ON call_edges(callee);
+
+-- 解析の起点となる関数
+CREATE TABLE entry_points (
+	kind     TEXT NOT NULL,                           -- main / grpc / http / test
+	name     TEXT NOT NULL,                           -- 表示名 (例: ExampleServer.Culc, /login loginHandler)
+	function TEXT NOT NULL REFERENCES functions(id),
+	service  TEXT                                     -- grpc の場合のサービス名
+);
+
+-- .proto に定義された rpc と、生成コード・実装メソッドとの対応
+CREATE TABLE proto_methods (
+	file           TEXT NOT NULL,    -- .proto ファイル (絶対パス)
+	line           INTEGER NOT NULL,
+	package        TEXT NOT NULL,    -- proto のパッケージ
+	service        TEXT NOT NULL,
+	method         TEXT NOT NULL,
+	request        TEXT NOT NULL,    -- リクエストメッセージ名
+	response       TEXT NOT NULL,    -- レスポンスメッセージ名
+	client_stream  INTEGER NOT NULL, -- 0 / 1
+	server_stream  INTEGER NOT NULL, -- 0 / 1
+	generated      TEXT,             -- 生成された <Service>Server インターフェイスのメソッドの関数 ID
+	implementation TEXT REFERENCES functions(id) -- サービス登録から見つかった実装メソッド
+);
+`
+
+// runExport は関数・パッケージ・呼び出し辺・エントリーポイント・proto の rpc を SQLite ファイルに書き出す。
+// テーブルは次の 5 つ (列の詳細は sqliteSchema のコメントを参照)。
+//
+//	packages       解析対象のパッケージ (path, name, module)
+//	functions      解析対象のパッケージで宣言された関数・メソッド (id, package, name, file, line, col)
+//	call_edges     関数から関数への参照 (caller, callee, kind, file, line, col)。
+//	               kind は call / interface / go / defer / reference
+//	entry_points   main / grpc / http / test のエントリーポイント (kind, name, function, service)
+//	proto_methods  .proto の rpc と生成コード・実装メソッドの対応 (service, method, generated, implementation など)
+//
+// 関数 ID は「パッケージパス.(レシーバ型.)関数名」で、functions.id・call_edges.caller / callee・
+// entry_points.function・proto_methods.implementation で結合できる。
+//
+//	go run . export -dir ./example -o callgraph.db
+//	sqlite3 callgraph.db "SELECT callee, count(*) FROM call_edges GROUP BY callee"
+//	sqlite3 callgraph.db "SELECT e.name, c.callee FROM entry_points e JOIN call_edges c ON c.caller = e.function"
+func runExport(args []string) error {
+	fset := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	out := fset.String("o", "callgraph.db", "output SQLite file (overwritten if it exists)")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, true)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+	refs, err := buildProtoXrefs(*dir, pkgs, pkgMap)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(*out); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite", *out)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := exportCallGraph(tx, pkgs, pkgMap, refs); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", *out)
+	return nil
+}
+
+// exportCallGraph はスキーマを作成し、各テーブルに行を挿入する
+func exportCallGraph(tx *sql.Tx, pkgs []*packages.Package, pkgMap map[string]*packages.Package, refs []*protoXref) error {
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("creating schema: %w", err)
+	}
+
+	for _, pkg := range pkgs {
+		var module any
+		if pkg.Module != nil {
+			module = pkg.Module.Path
+		}
+		if _, err := tx.Exec(`INSERT INTO packages (path, name, module) VALUES (?, ?, ?)`, pkg.PkgPath, pkg.Name, module); err != nil {
+			return fmt.Errorf("inserting package %s: %w", pkg.PkgPath, err)
+		}
+
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+				def := &FunctionDefinition{Pkg: pkg.Name, Name: fn.Name.Name, Node: fn, Package: pkg}
+				pos := pkg.Fset.Position(fn.Pos())
+				// init は 1 つのパッケージに複数書けるため、同じ ID は最初の宣言だけを登録する
+				if _, err := tx.Exec(`INSERT OR IGNORE INTO functions (id, package, name, file, line, col) VALUES (?, ?, ?, ?, ?, ?)`,
+					functionID(def), pkg.PkgPath, funcDeclName(fn), pos.Filename, pos.Line, pos.Column); err != nil {
+					return fmt.Errorf("inserting function %s: %w", functionID(def), err)
+				}
+				for _, e := range callEdges(def) {
+					if _, err := tx.Exec(`INSERT INTO call_edges (caller, callee, kind, file, line, col) VALUES (?, ?, ?, ?, ?, ?)`,
+						functionID(def), e.Callee, e.Kind, e.Pos.Filename, e.Pos.Line, e.Pos.Column); err != nil {
+						return fmt.Errorf("inserting call edge: %w", err)
+					}
+				}
+			}
+		}
+	}
+
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		var service any
+		if ep.Service != "" {
+			service = ep.Service
+		}
+		if _, err := tx.Exec(`INSERT INTO entry_points (kind, name, function, service) VALUES (?, ?, ?, ?)`,
+			ep.Kind, ep.Name, functionID(ep.Def), service); err != nil {
+			return fmt.Errorf("inserting entry point %s: %w", ep.Name, err)
+		}
+	}
+
+	for _, ref := range refs {
+		var generated, impl any
+		if ref.Generated != nil {
+			generated = funcObjID(ref.Generated)
+		}
+		if ref.Impl != nil {
+			impl = functionID(ref.Impl.Def)
+		}
+		// Go のソースの位置と同じく絶対パスにして、file で他のテーブルと結合できるようにする
+		path, err := filepath.Abs(ref.File.Path)
+		if err != nil {
+			return err
+		}
+		m := ref.Method
+		if _, err := tx.Exec(`INSERT INTO proto_methods (file, line, package, service, method, request, response, client_stream, server_stream, generated, implementation)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			path, m.Line, ref.File.Package, ref.Service.Name, m.Name, m.Request, m.Response, m.ClientStream, m.ServerStream, generated, impl); err != nil {
+			return fmt.Errorf("inserting proto method %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// callEdge は関数本体から別の関数への 1 つの参照
+type callEdge struct {
+	Callee string // 呼び出し先の関数 ID
+	Kind   string // call / interface / go / defer / reference
+	Pos    token.Position
+}
+
+// callEdges は fn の本体 (関数リテラルを含む) で参照している関数を、参照の種類と位置付きで返す
+func callEdges(fn *FunctionDefinition) []*callEdge {
+	if fn.Node.Body == nil {
+		return nil
+	}
+	typesInfo := fn.Package.TypesInfo
+
+	// go / defer 文の呼び出しと、呼び出し先を表す識別子の種類を先に記録しておく
+	// (ast.Inspect は親ノードを先に訪れるので、識別子にたどり着く前に決まっている)
+	stmtKind := make(map[*ast.CallExpr]string)
+	identKind := make(map[*ast.Ident]string)
+	var edges []*callEdge
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GoStmt:
+			stmtKind[n.Call] = "go"
+		case *ast.DeferStmt:
+			stmtKind[n.Call] = "defer"
+		case *ast.CallExpr:
+			ident := getCallIdent(n)
+			if ident == nil {
+				return true
+			}
+			kind := "call"
+			if sel, ok := unwrapIndexExpr(n.Fun).(*ast.SelectorExpr); ok {
+				if s := typesInfo.Selections[sel]; s != nil && types.IsInterface(s.Recv()) {
+					kind = "interface"
+				}
+			}
+			if k, ok := stmtKind[n]; ok {
+				kind = k
+			}
+			identKind[ident] = kind
+		case *ast.Ident:
+			obj, ok := typesInfo.Uses[n].(*types.Func)
+			if !ok {
+				return true
+			}
+			kind, ok := identKind[n]
+			if !ok {
+				kind = "reference"
+			}
+			edges = append(edges, &callEdge{Callee: funcObjID(obj), Kind: kind, Pos: fn.Package.Fset.Position(n.Pos())})
+		}
+		return true
+	})
+	return edges
+}
+
+// funcObjID は types.Func から functionID と同じ形式の関数 ID を組み立てる (解析対象外の関数にも使える)
+func funcObjID(obj *types.Func) string {
+	obj = obj.Origin()
+	name := obj.Name()
+	if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+		if named := namedType(sig.Recv().Type()); named != nil {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	if obj.Pkg() == nil {
+		return name // error.Error などのユニバーススコープのメソッド
+	}
+	return obj.Pkg().Path() + "." + name
+}
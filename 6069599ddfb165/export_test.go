@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// exportDB は dir を export した SQLite データベースを開く
+func exportDB(t *testing.T, dir string) *sql.DB {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "callgraph.db")
+	runOutput(t, "export", "-dir", dir, "-o", out)
+	db, err := sql.Open("sqlite", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// queryStrings は 1 列の文字列を返すクエリの結果を返す
+func queryStrings(t *testing.T, db *sql.DB, query string, args ...any) []string {
+	t.Helper()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var s sql.NullString
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, s.String)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestExportExample(t *testing.T) {
+	db := exportDB(t, "./example")
+	const server = "github.com/shunta-furukawa/zenn-demo/6069599ddfb165/example/server"
+
+	got := queryStrings(t, db, `SELECT c.callee FROM entry_points e JOIN call_edges c ON c.caller = e.function
+		WHERE e.kind = 'grpc' AND e.name = 'ExampleServer.Culc' ORDER BY c.line, c.col`)
+	want := []string{server + ".CulcService.Multiply", server + ".PrintService.Print"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("callees of Culc = %v, want %v", got, want)
+	}
+
+	got = queryStrings(t, db, `SELECT p.service || '/' || p.method || ' -> ' || f.name FROM proto_methods p JOIN functions f ON f.id = p.implementation`)
+	if want := []string{"ExampleService/Culc -> ExampleServer.Culc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("proto methods = %v, want %v", got, want)
+	}
+}
+
+func TestExportFixture(t *testing.T) {
+	db := exportDB(t, fixtureDir(t, "xref"))
+
+	got := queryStrings(t, db, `SELECT path FROM packages ORDER BY path`)
+	if want := []string{"fixture/xref", "fixture/xref/storepb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("packages = %v, want %v", got, want)
+	}
+	// lookup は 2 つの RPC から呼ばれる
+	got = queryStrings(t, db, `SELECT f.name FROM call_edges c JOIN functions f ON f.id = c.caller
+		WHERE c.callee = 'fixture/xref.lookup' AND c.kind = 'call' ORDER BY f.name`)
+	if want := []string{"server.GetItem", "server.ListItems"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("callers of lookup = %v, want %v", got, want)
+	}
+	// 実装のない rpc は implementation が NULL になる
+	got = queryStrings(t, db, `SELECT method FROM proto_methods WHERE implementation IS NULL`)
+	if want := []string{"DeleteItem"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unimplemented methods = %v, want %v", got, want)
+	}
+}
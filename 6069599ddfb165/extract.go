@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// runExtract はエントリーポイントから実際に呼ばれている対象の型のメソッドだけを集めたインターフェイスと、
+// そのインターフェイスを使うように書き換えた構造体の宣言を出力する (既存のサービスにモックを差し込むため)
+//
+//	go run . extract -entry ExampleServer.Culc -type CulcService
+func runExtract(args []string) error {
+	fset := flag.NewFlagSet("extract", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	entry := fset.String("entry", "", "entry point (e.g. ExampleServer.Culc, /login loginHandler)")
+	typeName := fset.String("type", "", "receiver type to extract (e.g. CulcService or server.CulcService)")
+	name := fset.String("name", "", "interface name (default: <type>Interface)")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	target := findNamedType(pkgs, *typeName)
+	if target == nil {
+		return fmt.Errorf("type %q not found", *typeName)
+	}
+	var ep *EntryPoint
+	var names []string
+	for _, e := range collectEntryPoints(pkgs, pkgMap) {
+		names = append(names, e.Name)
+		if e.Name == *entry || e.Def.Name == *entry {
+			ep = e
+			break
+		}
+	}
+	if ep == nil {
+		return fmt.Errorf("entry point %q not found (available: %s)", *entry, strings.Join(names, ", "))
+	}
+	if *name == "" {
+		*name = target.Obj().Name() + "Interface"
+	}
+
+	reached := entryReachableFuncs(ep, pkgMap)
+	methods := usedMethods(reached, target)
+	if len(methods) == 0 {
+		return fmt.Errorf("%s does not call any method of %s", ep.Name, target.Obj().Name())
+	}
+
+	fields := targetFields(pkgs, reached, target)
+	for _, f := range fields {
+		src, err := extractedSource(f, methods, *name, pkgMap)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("// %s.%s (%s)\n", f.Pkg.Name, f.Spec.Name.Name, f.Pkg.Fset.Position(f.Spec.Pos()))
+		fmt.Println(src)
+		for _, w := range fieldUseWarnings(pkgs, f, methods) {
+			fmt.Printf("// WARNING: %s\n", w)
+		}
+	}
+	if len(fields) > 0 {
+		return nil
+	}
+
+	// 構造体のフィールドを経由していない場合はインターフェイスだけを出力する
+	src, err := format.Source([]byte(interfaceSource(*name, methods, target.Obj().Pkg(), pkgMap)))
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(src))
+	fmt.Printf("// no struct field of type %s is used from %s\n", target.Obj().Name(), ep.Name)
+	return nil
+}
+
+// findNamedType は "CulcService" または "server.CulcService" 形式の名前で、解析対象パッケージの型を探す
+func findNamedType(pkgs []*packages.Package, name string) *types.Named {
+	pkgName, typeName, qualified := strings.Cut(name, ".")
+	if !qualified {
+		pkgName, typeName = "", name
+	}
+	for _, pkg := range pkgs {
+		if pkgName != "" && pkg.Name != pkgName {
+			continue
+		}
+		if obj, ok := pkg.Types.Scope().Lookup(typeName).(*types.TypeName); ok {
+			if named, ok := obj.Type().(*types.Named); ok {
+				return named
+			}
+		}
+	}
+	return nil
+}
+
+// usedMethods は reached 内の関数 (target 自身のメソッドは除く) から呼ばれている target のメソッドを名前順に返す。
+// target のメソッド内部での呼び出し (Multiply から Add など) はインターフェイスの外側から見えないので含めない。
+func usedMethods(reached map[string]*FunctionDefinition, target *types.Named) []*types.Func {
+	used := make(map[string]*types.Func)
+	for _, fn := range sortedFuncs(reached) {
+		if recv := receiverNamed(fn); recv != nil && recv.Obj() == target.Obj() {
+			continue
+		}
+		ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			s := fn.Package.TypesInfo.Selections[sel]
+			if s == nil || s.Kind() != types.MethodVal {
+				return true
+			}
+			if named := namedType(s.Recv()); named != nil && named.Obj() == target.Obj() {
+				used[s.Obj().Name()] = s.Obj().(*types.Func)
+			}
+			return true
+		})
+	}
+	methods := make([]*types.Func, 0, len(used))
+	for _, m := range used {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name() < methods[j].Name() })
+	return methods
+}
+
+// receiverNamed はメソッドのレシーバの名前付き型を返す (関数なら nil)
+func receiverNamed(fn *FunctionDefinition) *types.Named {
+	obj, ok := fn.Package.TypesInfo.Defs[fn.Node.Name].(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := obj.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	return namedType(recv.Type())
+}
+
+// targetField は書き換え対象の構造体フィールド
+type targetField struct {
+	Pkg   *packages.Package
+	Spec  *ast.TypeSpec
+	Field *ast.Field
+	Var   *types.Var // フィールドの型情報 (フィールド名が複数ある場合は最初のもの)
+}
+
+// targetFields は reached 内のメソッドのレシーバになっている構造体のうち、target (または *target) 型の
+// フィールドを持つものを返す
+func targetFields(pkgs []*packages.Package, reached map[string]*FunctionDefinition, target *types.Named) []*targetField {
+	consumers := make(map[*types.TypeName]bool)
+	for _, fn := range reached {
+		if recv := receiverNamed(fn); recv != nil && recv.Obj() != target.Obj() {
+			consumers[recv.Obj()] = true
+		}
+	}
+
+	var fields []*targetField
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok || !consumers[pkg.TypesInfo.Defs[ts.Name].(*types.TypeName)] {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						named := namedType(pkg.TypesInfo.TypeOf(field.Type))
+						if named == nil || named.Obj() != target.Obj() || len(field.Names) == 0 {
+							continue // 埋め込みフィールドはメソッドが昇格するため対象外
+						}
+						fields = append(fields, &targetField{
+							Pkg:   pkg,
+							Spec:  ts,
+							Field: field,
+							Var:   pkg.TypesInfo.Defs[field.Names[0]].(*types.Var),
+						})
+					}
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// interfaceSource はメソッドの一覧からインターフェイス宣言のソースを組み立てる。
+// シグネチャの型は pkg から見た修飾名で書く。
+func interfaceSource(name string, methods []*types.Func, pkg *types.Package, pkgMap map[string]*packages.Package) string {
+	qualifier := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "type %s interface {\n", name)
+	for _, m := range methods {
+		if def := findFuncDecl(m, pkgMap); def != nil && def.Node.Doc != nil {
+			for _, c := range def.Node.Doc.List {
+				fmt.Fprintf(&sb, "%s\n", c.Text)
+			}
+		}
+		var sig bytes.Buffer
+		types.WriteSignature(&sig, m.Type().(*types.Signature), qualifier)
+		fmt.Fprintf(&sb, "%s%s\n", m.Name(), sig.String())
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// extractedSource はインターフェイス宣言と、フィールドの型をインターフェイスに置き換えた構造体宣言を
+// go/format で整形して返す
+func extractedSource(f *targetField, methods []*types.Func, name string, pkgMap map[string]*packages.Package) (string, error) {
+	// 読み込んだ AST を書き換えるので、出力したら元の型に戻す
+	orig := f.Field.Type
+	f.Field.Type = ast.NewIdent(name)
+	defer func() { f.Field.Type = orig }()
+
+	var decl bytes.Buffer
+	single := &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{f.Spec}} // type ( ... ) のグループから取り出す
+	if err := format.Node(&decl, f.Pkg.Fset, single); err != nil {
+		return "", err
+	}
+
+	src := interfaceSource(name, methods, f.Pkg.Types, pkgMap) + "\n" + decl.String()
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return "", fmt.Errorf("formatting generated source: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// fieldUseWarnings は書き換えるフィールドが、インターフェイスに含まれないメソッドの呼び出しや
+// 具体型としての利用 (関数への引数など) に使われている箇所を返す。書き換え後にコンパイルできなくなる可能性がある。
+func fieldUseWarnings(pkgs []*packages.Package, f *targetField, methods []*types.Func) []string {
+	inInterface := make(map[string]bool)
+	for _, m := range methods {
+		inInterface[m.Name()] = true
+	}
+
+	var warnings []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			// s.CulcService.Multiply のように、フィールドの選択式をさらに選択している外側の式と代入先を記録する
+			outer := make(map[*ast.SelectorExpr]*ast.SelectorExpr)
+			assigned := make(map[ast.Expr]bool)
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					if inner, ok := n.X.(*ast.SelectorExpr); ok {
+						outer[inner] = n
+					}
+				case *ast.AssignStmt:
+					for _, lhs := range n.Lhs {
+						assigned[lhs] = true
+					}
+				}
+				return true
+			})
+			ast.Inspect(file, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if s := pkg.TypesInfo.Selections[sel]; s == nil || s.Obj() != f.Var {
+					return true
+				}
+				pos := pkg.Fset.Position(sel.Pos())
+				switch o := outer[sel]; {
+				case o != nil:
+					if s := pkg.TypesInfo.Selections[o]; s != nil && s.Kind() == types.MethodVal && !inInterface[o.Sel.Name] {
+						warnings = append(warnings, fmt.Sprintf("%s: %s calls %s, which is not in the interface", pos, types.ExprString(sel), o.Sel.Name))
+					} else if s != nil && s.Kind() == types.FieldVal {
+						warnings = append(warnings, fmt.Sprintf("%s: %s accesses field %s, which an interface cannot provide", pos, types.ExprString(sel), o.Sel.Name))
+					}
+				case !assigned[sel]:
+					warnings = append(warnings, fmt.Sprintf("%s: %s is used as a concrete value; check it still compiles with the interface", pos, types.ExprString(sel)))
+				}
+				return true
+			})
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractExample(t *testing.T) {
+	out := runOutput(t, "extract", "-entry", "ExampleServer.Culc", "-type", "CulcService")
+	assertContains(t, out,
+		"// server.ExampleServer (",
+		// Multiply の内部で呼んでいる Add はインターフェイスに含めない
+		"type CulcServiceInterface interface {\n\t// Multiply メソッド: Add を使って掛け算を模倣する\n\tMultiply(a int32, b int32) int32\n}\n",
+		"\tCulcService  CulcServiceInterface\n\tPrintService *PrintService\n",
+	)
+	assertNotContains(t, out, "Add(", "WARNING")
+}
+
+func TestExtractFixture(t *testing.T) {
+	dir := fixtureDir(t, "extract")
+
+	out := runOutput(t, "extract", "-dir", dir, "-entry", "/put Handler.put", "-type", "Store")
+	assertContains(t, out,
+		"type StoreInterface interface {\n\t// Get は key の値を返す\n\tGet(key string) string\n\t// Put は key に value を保存する\n\tPut(key string, value string)\n}\n",
+		"type Handler struct {\n\tstore StoreInterface\n\tname  string\n}\n",
+		"main.go:46:29: h.store calls Delete, which is not in the interface",
+		"main.go:48:41: h.store accesses field dir, which an interface cannot provide",
+		"main.go:50:42: h.store is used as a concrete value; check it still compiles with the interface",
+	)
+	// Store 自身のメソッドからの呼び出し (Get → load) は含めない
+	assertNotContains(t, out, "load(", "Delete(")
+
+	// /get からは Put を呼んでいない
+	out = runOutput(t, "extract", "-dir", dir, "-entry", "/get Handler.get", "-type", "Store")
+	assertNotContains(t, out, "Put(")
+
+	// 構造体のフィールドを経由しない場合はインターフェイスだけを出力する
+	out = runOutput(t, "extract", "-dir", dir, "-entry", "dumpHandler", "-type", "main.Store", "-name", "Getter")
+	assertContains(t, out,
+		"type Getter interface {\n\t// Get は key の値を返す\n\tGet(key string) string\n}\n",
+		"// no struct field of type Store is used from /dump dumpHandler\n",
+	)
+}
+
+func TestExtractErrors(t *testing.T) {
+	dir := fixtureDir(t, "extract")
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-entry", "healthHandler", "-type", "Store"}, "/health healthHandler does not call any method of Store"},
+		{[]string{"-entry", "/nope", "-type", "Store"}, `entry point "/nope" not found (available: `},
+		{[]string{"-entry", "dumpHandler", "-type", "Nope"}, `type "Nope" not found`},
+		{[]string{"-entry", "dumpHandler", "-type", "other.Store"}, `type "other.Store" not found`},
+	} {
+		err := runExtract(append([]string{"-dir", dir}, tt.args...))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("extract %s: got error %v, want %q", strings.Join(tt.args, " "), err, tt.want)
+		}
+	}
+}
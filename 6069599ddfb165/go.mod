@@ -2,9 +2,21 @@ module github.com/shunta-furukawa/zenn-demo/6069599ddfb165
 
 go 1.23.4
 
-require golang.org/x/tools v0.29.0
+require (
+	golang.org/x/mod v0.22.0
+	golang.org/x/tools v0.29.0
+	modernc.org/sqlite v1.34.5
+)
 
 require (
-	golang.org/x/mod v0.22.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
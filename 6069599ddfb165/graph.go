@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// CallNode は呼び出しツリーの 1 ノード (1 つの関数呼び出し) を表す。
+// extractCallSequence が標準出力に書き出している内容を、UI などから扱えるように保持したもの。
+type CallNode struct {
+	Label    string      `json:"label"`            // 表示名 (例: s.CulcService.Multiply)
+	FuncID   string      `json:"funcId,omitempty"` // 解決できた呼び出し先関数の ID (外部関数なら空)
+	Pos      string      `json:"pos"`              // 呼び出し位置 (file:line:col)
+	Children []*CallNode `json:"children,omitempty"`
+}
+
+// FunctionInfo は解析対象パッケージ内の関数定義のメタ情報
+type FunctionInfo struct {
+	ID   string `json:"id"`   // パッケージパス + (レシーバ型.)関数名
+	Name string `json:"name"` // 表示名 (例: CulcService.Multiply)
+	Pkg  string `json:"pkg"`  // パッケージパス
+	Pos  string `json:"pos"`  // 定義位置 (file:line:col)
+}
+
+// RPCEntry は gRPC サービス登録から見つかった RPC 実装メソッドとその呼び出しツリー
+type RPCEntry struct {
+	Name   string    `json:"name"`   // 例: ExampleServer.Culc
+	FuncID string    `json:"funcId"` // 実装メソッドの関数 ID
+	Tree   *CallNode `json:"tree"`   // 実装メソッドをルートとする呼び出しツリー
+}
+
+// CallGraph は解析結果をまとめたもの
+type CallGraph struct {
+	RPCs      []*RPCEntry                    `json:"rpcs"`
+	Functions []*FunctionInfo                `json:"functions"`
+	defs      map[string]*FunctionDefinition // 関数 ID → 定義 (ソース表示用)
+}
+
+// buildCallGraph は読み込んだパッケージから RPC ごとの呼び出しツリーと関数一覧を組み立てる
+func buildCallGraph(pkgs []*packages.Package, pkgMap map[string]*packages.Package) *CallGraph {
+	g := &CallGraph{defs: make(map[string]*FunctionDefinition)}
+
+	// 関数一覧 (検索用)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+				def := &FunctionDefinition{Pkg: pkg.Name, Name: fn.Name.Name, Node: fn, Package: pkg}
+				id := functionID(def)
+				g.defs[id] = def
+				g.Functions = append(g.Functions, &FunctionInfo{
+					ID:   id,
+					Name: funcDeclName(fn),
+					Pkg:  pkg.PkgPath,
+					Pos:  pkg.Fset.Position(fn.Pos()).String(),
+				})
+			}
+		}
+	}
+	sort.Slice(g.Functions, func(i, j int) bool { return g.Functions[i].ID < g.Functions[j].ID })
+
+	// gRPC サービス登録から見つかった RPC 実装メソッドごとに呼び出しツリーを組み立てる
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		if ep.Kind != EntryGRPC {
+			continue
+		}
+		root := &CallNode{
+			Label:  ep.Name,
+			FuncID: functionID(ep.Def),
+			Pos:    ep.Def.Package.Fset.Position(ep.Def.Node.Pos()).String(),
+		}
+		visited := make(map[string]bool)
+		root.Children = buildCallTree(ep.Def.Node.Body, ep.Def.Package, pkgMap, visited)
+		g.RPCs = append(g.RPCs, &RPCEntry{Name: root.Label, FuncID: root.FuncID, Tree: root})
+	}
+	return g
+}
+
+// エントリーポイントの種類
+const (
+	EntryMain = "main" // main 関数
+	EntryGRPC = "grpc" // gRPC サービス登録から見つかった RPC 実装メソッド
+	EntryHTTP = "http" // HandleFunc / Handle で登録された HTTP ハンドラ
+	EntryTest = "test" // _test.go 内の TestXxx 関数
+)
+
+// EntryPoint は解析の起点となる関数
+type EntryPoint struct {
+	Kind    string              // EntryMain / EntryGRPC / EntryHTTP / EntryTest
+	Name    string              // 表示名 (例: ExampleServer.Culc, /login loginHandler, TestCulc)
+	Def     *FunctionDefinition // 起点となる関数定義
+	Service string              // EntryGRPC の場合のサービス名 (例: ExampleService)
+	// EntryGRPC の場合に Register<Service>Server を定義している生成コードのパッケージパス
+	// (import が解決できず分からなければ空)
+	GenPkg string
+	// EntryHTTP の場合にハンドラを包んでいるミドルウェア (外側から順に)。
+	// ミドルウェアは next.ServeHTTP のようにインタフェース経由でハンドラを呼ぶので、Def とは別の起点として扱う。
+	Middleware []*FunctionDefinition
+}
+
+// roots はエントリーポイントの起点となる関数定義を、リクエストが通る順 (ミドルウェア、ハンドラ) に返す
+func (ep *EntryPoint) roots() []*FunctionDefinition {
+	return append(append([]*FunctionDefinition{}, ep.Middleware...), ep.Def)
+}
+
+// isRoot は fn がエントリーポイントの起点 (ハンドラまたはミドルウェア) かを返す
+func (ep *EntryPoint) isRoot(fn *FunctionDefinition) bool {
+	for _, root := range ep.roots() {
+		if functionID(root) == functionID(fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectEntryPoints は読み込んだパッケージからエントリーポイントを集める
+func collectEntryPoints(pkgs []*packages.Package, pkgMap map[string]*packages.Package) []*EntryPoint {
+	var eps []*EntryPoint
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			isTestFile := strings.HasSuffix(pkg.Fset.Position(file.Pos()).Filename, "_test.go")
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil {
+					continue
+				}
+				def := &FunctionDefinition{Pkg: pkg.Name, Name: fn.Name.Name, Node: fn, Package: pkg}
+				switch {
+				case pkg.Name == "main" && fn.Name.Name == "main" && !isTestFile:
+					eps = append(eps, &EntryPoint{Kind: EntryMain, Name: pkg.PkgPath + ".main", Def: def})
+				case isTestFile && isTestFunc(fn):
+					eps = append(eps, &EntryPoint{Kind: EntryTest, Name: fn.Name.Name, Def: def})
+				}
+			}
+			for _, srv := range findRegisteredServers(file, pkg.TypesInfo) {
+				for _, fnDef := range serverMethodDecls(srv.Type, pkgMap) {
+					eps = append(eps, &EntryPoint{Kind: EntryGRPC, Name: funcDeclName(fnDef.Node), Def: fnDef, Service: srv.Service, GenPkg: srv.GenPkg})
+				}
+			}
+			eps = append(eps, findHTTPHandlers(file, pkg.TypesInfo, pkgMap)...)
+		}
+	}
+	return eps
+}
+
+// isTestFunc は fn が func TestXxx(t *testing.T) 形式のテスト関数かどうかを判定する
+func isTestFunc(fn *ast.FuncDecl) bool {
+	if !strings.HasPrefix(fn.Name.Name, "Test") || fn.Name.Name == "TestMain" {
+		return false
+	}
+	params := fn.Type.Params.List
+	if len(params) != 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && getIdentName(sel.X) == "testing" && sel.Sel.Name == "T"
+}
+
+// findHTTPHandlers は HandleFunc("/path", handler) / Handle("/path", handler) の呼び出しから
+// HTTP ハンドラ関数を探す。validateTokenMiddleware(http.HandlerFunc(protectedHandler)) のように
+// ミドルウェアで包まれている場合は、最も内側で参照されている関数をハンドラ、その外側をミドルウェアとみなす。
+func findHTTPHandlers(file *ast.File, typesInfo *types.Info, pkgMap map[string]*packages.Package) []*EntryPoint {
+	var eps []*EntryPoint
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "HandleFunc" && sel.Sel.Name != "Handle") {
+			return true
+		}
+		// ast.Inspect は外側の式から順にたどるので、参照されている関数も外側から順に並ぶ
+		var chain []*FunctionDefinition
+		seen := make(map[string]bool)
+		ast.Inspect(call.Args[1], func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				if obj, ok := typesInfo.Uses[ident].(*types.Func); ok {
+					if def := findFuncDecl(obj, pkgMap); def != nil && !seen[functionID(def)] {
+						seen[functionID(def)] = true
+						chain = append(chain, def)
+					}
+				}
+			}
+			return true
+		})
+		if len(chain) == 0 {
+			return true
+		}
+		handler := chain[len(chain)-1]
+		route := "(unknown route)"
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok {
+			route = strings.Trim(lit.Value, "`\"")
+		}
+		eps = append(eps, &EntryPoint{
+			Kind:       EntryHTTP,
+			Name:       route + " " + funcDeclName(handler.Node),
+			Def:        handler,
+			Middleware: chain[:len(chain)-1],
+		})
+		return true
+	})
+	return eps
+}
+
+// reachableFuncs は def から静的にたどれる関数定義を関数 ID をキーにして返す (def 自身を含む)。
+// 呼び出しだけでなく、http.HandlerFunc(h) のように関数を値として参照している箇所もたどる。
+func reachableFuncs(def *FunctionDefinition, pkgMap map[string]*packages.Package) map[string]*FunctionDefinition {
+	reached := make(map[string]*FunctionDefinition)
+	var walk func(fn *FunctionDefinition)
+	walk = func(fn *FunctionDefinition) {
+		id := functionID(fn)
+		if reached[id] != nil {
+			return
+		}
+		reached[id] = fn
+		if fn.Node.Body == nil {
+			return
+		}
+		ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if obj, ok := fn.Package.TypesInfo.Uses[ident].(*types.Func); ok {
+				if callee := findFuncDecl(obj, pkgMap); callee != nil {
+					walk(callee)
+				}
+			}
+			return true
+		})
+	}
+	walk(def)
+	return reached
+}
+
+// entryReachableFuncs は ep の起点 (ミドルウェアとハンドラ) から静的にたどれる関数定義を関数 ID をキーにして返す
+func entryReachableFuncs(ep *EntryPoint, pkgMap map[string]*packages.Package) map[string]*FunctionDefinition {
+	reached := make(map[string]*FunctionDefinition)
+	for _, root := range ep.roots() {
+		for id, fn := range reachableFuncs(root, pkgMap) {
+			reached[id] = fn
+		}
+	}
+	return reached
+}
+
+// sortedFuncs は reachableFuncs の結果を関数 ID 順に並べて返す (出力順を安定させるため)
+func sortedFuncs(reached map[string]*FunctionDefinition) []*FunctionDefinition {
+	ids := make([]string, 0, len(reached))
+	for id := range reached {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	fns := make([]*FunctionDefinition, len(ids))
+	for i, id := range ids {
+		fns[i] = reached[id]
+	}
+	return fns
+}
+
+// buildCallTree は extractCallSequence と同じ順序で呼び出しをたどり、出力の代わりにツリーを返す。
+// 呼び出し先の本体は、その関数が定義されているパッケージの型情報で解析する。
+func buildCallTree(node ast.Node, pkg *packages.Package, pkgMap map[string]*packages.Package, visited map[string]bool) []*CallNode {
+	var nodes []*CallNode
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		cn := &CallNode{
+			Label: callLabel(call, pkg.TypesInfo),
+			Pos:   pkg.Fset.Position(call.Pos()).String(),
+		}
+		nodes = append(nodes, cn)
+
+		if fn := getFunctionDefinition(call, pkg.TypesInfo, pkgMap); fn != nil {
+			cn.FuncID = functionID(fn)
+			if !visited[cn.FuncID] {
+				visited[cn.FuncID] = true
+				cn.Children = buildCallTree(fn.Node.Body, fn.Package, pkgMap, visited)
+			}
+		}
+		return true
+	})
+	return nodes
+}
+
+// registeredServer は gRPC サービス登録で見つかったサービス名とサーバ実装の型
+type registeredServer struct {
+	Service string       // 例: ExampleService
+	Type    *types.Named // 例: server.ExampleServer
+	GenPkg  string       // Register<Service>Server を定義しているパッケージのパス (解決できなければ空)
+}
+
+// findRegisteredServers は RegisterExampleServiceServer(...) のような Register<Service>Server(...) の
+// 第2引数に渡されたサーバ実装の型を返す
+func findRegisteredServers(file *ast.File, typesInfo *types.Info) []*registeredServer {
+	var servers []*registeredServer
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isServiceRegistration(sel.Sel.Name) || len(call.Args) != 2 {
+			return true
+		}
+		// 変数だけでなく &server{} のような式も渡されるため、式の型から実装の型を求める
+		t := typesInfo.TypeOf(call.Args[1])
+		if t == nil {
+			return true
+		}
+		if named := namedType(t); named != nil {
+			service := strings.TrimSuffix(strings.TrimPrefix(sel.Sel.Name, "Register"), "Server")
+			srv := &registeredServer{Service: service, Type: named}
+			if fn, ok := typesInfo.Uses[sel.Sel].(*types.Func); ok && fn.Pkg() != nil {
+				srv.GenPkg = fn.Pkg().Path()
+			}
+			servers = append(servers, srv)
+		}
+		return true
+	})
+	return servers
+}
+
+// isServiceRegistration は protoc-gen-go-grpc が生成するサービス登録関数名かどうかを判定する
+func isServiceRegistration(name string) bool {
+	return strings.HasPrefix(name, "Register") && strings.HasSuffix(name, "Server") && name != "RegisterServer"
+}
+
+// serverMethodDecls はサーバ型の公開メソッドに対応する FuncDecl を、型を定義しているパッケージから探す
+func serverMethodDecls(named *types.Named, pkgMap map[string]*packages.Package) []*FunctionDefinition {
+	var defs []*FunctionDefinition
+	for i := 0; i < named.NumMethods(); i++ {
+		method := named.Method(i)
+		if !method.Exported() {
+			continue
+		}
+		// 同じパッケージに同名のメソッドを持つ型があっても取り違えないよう、宣言位置で照合する
+		if fnDef := findFuncDecl(method, pkgMap); fnDef != nil {
+			defs = append(defs, fnDef)
+		}
+	}
+	return defs
+}
+
+// namedType はポインタ型を剥がして *types.Named を取り出す (名前付き型でなければ nil)
+func namedType(t types.Type) *types.Named {
+	for {
+		ptr, ok := t.(*types.Pointer)
+		if !ok {
+			break
+		}
+		t = ptr.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// functionID は関数定義を一意に識別する ID (パッケージパス + レシーバ型 + 関数名) を返す
+func functionID(fn *FunctionDefinition) string {
+	return fmt.Sprintf("%s.%s", fn.Package.PkgPath, funcDeclName(fn.Node))
+}
+
+// funcDeclName は FuncDecl の表示名を返す。メソッドなら "Recv.Name"、関数なら "Name"。
+func funcDeclName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	return fmt.Sprintf("%s.%s", recvTypeName(fn.Recv.List[0].Type), fn.Name.Name)
+}
+
+// recvTypeName はレシーバの型式から型名を取り出す (*T や T[P] にも対応)
+func recvTypeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return recvTypeName(e.X)
+	case *ast.IndexExpr:
+		return recvTypeName(e.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return "unknown"
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectEntryPointsExample(t *testing.T) {
+	pkgs, pkgMap, err := loadPackages("./example", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eps := collectEntryPoints(pkgs, pkgMap)
+	culc := findEntry(t, eps, "ExampleServer.Culc")
+	if culc.Kind != EntryGRPC || culc.Service != "ExampleService" {
+		t.Errorf("ExampleServer.Culc: kind = %s, service = %s", culc.Kind, culc.Service)
+	}
+	findEntry(t, eps, "github.com/shunta-furukawa/zenn-demo/6069599ddfb165/example.main")
+
+	g := buildCallGraph(pkgs, pkgMap)
+	if len(g.RPCs) != 1 || g.RPCs[0].Name != "ExampleServer.Culc" {
+		t.Fatalf("RPCs = %+v", g.RPCs)
+	}
+	labels := make(map[string]bool)
+	var walk func(nodes []*CallNode)
+	walk = func(nodes []*CallNode) {
+		for _, n := range nodes {
+			labels[n.Label] = true
+			walk(n.Children)
+		}
+	}
+	walk(g.RPCs[0].Tree.Children)
+	// レシーバは式のまま表示する
+	for _, want := range []string{"s.CulcService.Multiply", "s.PrintService.Print"} {
+		if !labels[want] {
+			t.Errorf("call tree has no %q (labels: %v)", want, labels)
+		}
+	}
+}
+
+func TestServerMethodDeclsSameName(t *testing.T) {
+	pkgs, pkgMap := loadFixture(t, "samename")
+	ep := findEntry(t, collectEntryPoints(pkgs, pkgMap), "Store.Get")
+	// 同じパッケージの Cache.Get (ファイル名順で先に現れる) と取り違えない
+	if file := filepath.Base(ep.Def.Package.Fset.Position(ep.Def.Node.Pos()).Filename); file != "store.go" {
+		t.Errorf("Store.Get resolved to a declaration in %s", file)
+	}
+	if _, ok := reachableFuncs(ep.Def, pkgMap)["fixture/samename/server.lookup"]; !ok {
+		t.Error("lookup is not reachable from Store.Get")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/tools/go/packages"
@@ -12,34 +14,28 @@ import (
 
 // FunctionDefinition は、呼び出し先関数の情報をまとめた構造体
 type FunctionDefinition struct {
-	Pkg  string        // パッケージ名 (構造体やメソッドが属する実装パッケージ)
-	Name string        // 関数(メソッド)名
-	Node *ast.FuncDecl // 関数ノード
+	Pkg     string            // パッケージ名 (構造体やメソッドが属する実装パッケージ)
+	Name    string            // 関数(メソッド)名
+	Node    *ast.FuncDecl     // 関数ノード
+	Package *packages.Package // 関数が定義されているパッケージ (型情報や位置情報の取得に使う)
 }
 
 func main() {
-	cfg := &packages.Config{
-		Mode: packages.NeedName |
-			packages.NeedSyntax |
-			packages.NeedFiles |
-			packages.NeedTypes |
-			packages.NeedTypesInfo |
-			packages.NeedDeps,
-		Dir: "./example", // 解析対象ディレクトリ。必要に応じて調整
+	// サブコマンドが指定された場合はそちらを実行する (例: go run . serve)
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	pkgs, err := packages.Load(cfg, "./...")
+	pkgs, pkgMap, err := loadPackages("./example", false) // 解析対象ディレクトリ。必要に応じて調整
 	if err != nil {
 		fmt.Println("Error loading packages:", err)
 		return
 	}
 
-	// パッケージ情報をマップに格納 (あとで依存関係解析に使用)
-	pkgMap := make(map[string]*packages.Package)
-	for _, pkg := range pkgs {
-		pkgMap[pkg.PkgPath] = pkg
-	}
-
 	// --- 例: main 関数の呼び出し解析をやりたい場合 ---
 	fmt.Println("=== Analyzing main function calls ===")
 	for _, pkg := range pkgs {
@@ -57,6 +53,105 @@ func main() {
 	}
 }
 
+// loadPackages は dir 配下のパッケージを型情報付きで読み込み、パッケージパスをキーにしたマップも返す。
+// tests が true の場合は _test.go も含めて読み込む。
+// dir に go.work ファイルを指定した場合は、use されている全モジュールをまとめて読み込む。
+func loadPackages(dir string, tests bool) ([]*packages.Package, map[string]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName |
+			packages.NeedSyntax |
+			packages.NeedFiles |
+			packages.NeedTypes |
+			packages.NeedTypesInfo |
+			packages.NeedImports |
+			packages.NeedModule |
+			packages.NeedDeps,
+		Dir:   dir,
+		Tests: tests,
+	}
+
+	patterns := []string{"./..."}
+	if isWorkFile(dir) {
+		modules, err := workspaceModules(dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", dir, err)
+		}
+		work, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.Dir = filepath.Dir(work)
+		cfg.Env = append(os.Environ(), "GOWORK="+work)
+		patterns = patterns[:0]
+		for _, m := range modules {
+			patterns = append(patterns, m+"/...")
+		}
+	}
+
+	loaded, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// パッケージ情報をマップに格納 (あとで依存関係解析に使用)
+	// テストを含めて読み込むと同じパッケージが「通常版」と「テスト版 (_test.go を含む)」の
+	// 2 つ返ってくるため、ファイル数の多いテスト版を優先して 1 つにまとめる
+	pkgMap := make(map[string]*packages.Package)
+	var order []string
+	for _, pkg := range loaded {
+		if strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue // go test が生成する main パッケージは解析対象外
+		}
+		prev, ok := pkgMap[pkg.PkgPath]
+		if !ok {
+			order = append(order, pkg.PkgPath)
+		} else if len(prev.Syntax) >= len(pkg.Syntax) {
+			continue
+		}
+		pkgMap[pkg.PkgPath] = pkg
+	}
+	pkgs := make([]*packages.Package, 0, len(order))
+	for _, path := range order {
+		pkgs = append(pkgs, pkgMap[path])
+	}
+	return pkgs, pkgMap, nil
+}
+
+// runCommand は go run . <command> [flags] 形式で指定されたサブコマンドを実行する
+func runCommand(name string, args []string) error {
+	switch name {
+	case "serve":
+		return runServe(args)
+	case "tests":
+		return runTests(args)
+	case "sequence":
+		return runSequence(args)
+	case "errors":
+		return runErrors(args)
+	case "ctxcheck":
+		return runCtxCheck(args)
+	case "xref":
+		return runXref(args)
+	case "unusedfields":
+		return runUnusedFields(args)
+	case "concurrency":
+		return runConcurrency(args)
+	case "pkggraph":
+		return runPkgGraph(args)
+	case "export":
+		return runExport(args)
+	case "workspace":
+		return runWorkspace(args)
+	case "report":
+		return runReport(args)
+	case "panics":
+		return runPanics(args)
+	case "extract":
+		return runExtract(args)
+	}
+	return fmt.Errorf("unknown command: %s", name)
+}
+
 // analyzeMainFunction は、main 関数を探して呼び出しを解析
 func analyzeMainFunction(file *ast.File, fset *token.FileSet, typesInfo *types.Info, pkgMap map[string]*packages.Package) {
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -164,9 +259,10 @@ func findFunctionDeclInPackage(pkg *packages.Package, method types.Object) *Func
 			// （本サンプルでは名前だけを比較）
 			if fn.Name.Name == method.Name() {
 				return &FunctionDefinition{
-					Pkg:  method.Pkg().Name(), // or pkg.Name
-					Name: method.Name(),
-					Node: fn,
+					Pkg:     method.Pkg().Name(), // or pkg.Name
+					Name:    method.Name(),
+					Node:    fn,
+					Package: pkg,
 				}
 			}
 		}
@@ -180,7 +276,7 @@ func extractCallSequence(node ast.Node, depth int, typesInfo *types.Info, pkgMap
 	ast.Inspect(node, func(n ast.Node) bool {
 		call, ok := n.(*ast.CallExpr)
 		if ok {
-			printIndentedCall(call, depth)
+			printIndentedCall(call, depth, typesInfo)
 
 			// 呼び出し先の関数定義を取得
 			if fn := getFunctionDefinition(call, typesInfo, pkgMap); fn != nil {
@@ -201,6 +297,19 @@ func getFunctionDefinition(call *ast.CallExpr, typesInfo *types.Info, pkgMap map
 	if obj == nil || obj.Pkg() == nil {
 		return nil
 	}
+	return findFuncDecl(obj, pkgMap)
+}
+
+// findFuncDecl は関数オブジェクトに対応する FuncDecl を、定義元パッケージの AST から探す
+func findFuncDecl(obj types.Object, pkgMap map[string]*packages.Package) *FunctionDefinition {
+	// ジェネリック関数やジェネリック型のメソッドはインスタンス化されたオブジェクトになるため、
+	// 宣言と突き合わせられるよう元のジェネリックな定義に戻す
+	if f, ok := obj.(*types.Func); ok {
+		obj = f.Origin()
+	}
+	if obj.Pkg() == nil {
+		return nil
+	}
 	pkg := pkgMap[obj.Pkg().Path()]
 	if pkg == nil {
 		return nil
@@ -212,11 +321,13 @@ func getFunctionDefinition(call *ast.CallExpr, typesInfo *types.Info, pkgMap map
 			if !ok {
 				continue
 			}
-			if fn.Name.Name == obj.Name() {
+			// 同名のメソッドを取り違えないよう、宣言位置で照合する
+			if fn.Name.Pos() == obj.Pos() {
 				return &FunctionDefinition{
-					Pkg:  obj.Pkg().Name(),
-					Name: obj.Name(),
-					Node: fn,
+					Pkg:     obj.Pkg().Name(),
+					Name:    obj.Name(),
+					Node:    fn,
+					Package: pkg,
 				}
 			}
 		}
@@ -225,21 +336,68 @@ func getFunctionDefinition(call *ast.CallExpr, typesInfo *types.Info, pkgMap map
 }
 
 // printIndentedCall は呼び出しをインデント付きで表示するユーティリティ
-func printIndentedCall(call *ast.CallExpr, depth int) {
+func printIndentedCall(call *ast.CallExpr, depth int, typesInfo *types.Info) {
 	indent := strings.Repeat("  ", depth)
-	switch fun := call.Fun.(type) {
+	fmt.Printf("%s%s\n", indent, callLabel(call, typesInfo))
+}
+
+// callLabel は呼び出しの表示名 (例: fmt.Sprintf, Map[int string]) を返す。
+// ジェネリック関数の呼び出しでは型引数 (推論されたものを含む) も表示する。
+func callLabel(call *ast.CallExpr, typesInfo *types.Info) string {
+	var label string
+	switch fun := unwrapIndexExpr(call.Fun).(type) {
 	case *ast.SelectorExpr:
-		fmt.Printf("%s%s.%s\n", indent, getIdentName(fun.X), fun.Sel.Name)
+		// レシーバは式のまま表示する (例: s.CulcService.Multiply)
+		label = fmt.Sprintf("%s.%s", types.ExprString(fun.X), fun.Sel.Name)
+		// ジェネリック型のメソッドならレシーバのインスタンス型を併記する (例: s.Push (Stack[int]))
+		if sel := typesInfo.Selections[fun]; sel != nil {
+			if named := namedType(sel.Recv()); named != nil && named.TypeArgs().Len() > 0 {
+				label += fmt.Sprintf(" (%s)", types.TypeString(named, shortQualifier))
+			}
+		}
 	case *ast.Ident:
-		fmt.Printf("%s%s\n", indent, fun.Name)
+		label = fun.Name
 	default:
-		fmt.Printf("%s(Unknown call)\n", indent)
+		return "(Unknown call)"
+	}
+	return label + typeArgsString(getCallIdent(call), typesInfo)
+}
+
+// typeArgsString はジェネリック関数のインスタンス化に使われた型引数を "[int, string]" 形式で返す
+func typeArgsString(ident *ast.Ident, typesInfo *types.Info) string {
+	if ident == nil {
+		return ""
+	}
+	inst, ok := typesInfo.Instances[ident]
+	if !ok || inst.TypeArgs.Len() == 0 {
+		return ""
+	}
+	args := make([]string, inst.TypeArgs.Len())
+	for i := range args {
+		args[i] = types.TypeString(inst.TypeArgs.At(i), shortQualifier)
+	}
+	return "[" + strings.Join(args, ", ") + "]"
+}
+
+// shortQualifier は型名をパッケージ名で修飾する (フルパスだと出力が長くなるため)
+func shortQualifier(pkg *types.Package) string {
+	return pkg.Name()
+}
+
+// unwrapIndexExpr は F[int] や F[int, string] のような明示的なインスタンス化を剥がして F を返す
+func unwrapIndexExpr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		return e.X
+	case *ast.IndexListExpr:
+		return e.X
 	}
+	return expr
 }
 
 // getCallIdent は、CallExpr の呼び出し先識別子 (関数名) を取得
 func getCallIdent(call *ast.CallExpr) *ast.Ident {
-	switch fun := call.Fun.(type) {
+	switch fun := unwrapIndexExpr(call.Fun).(type) {
 	case *ast.Ident:
 		return fun
 	case *ast.SelectorExpr:
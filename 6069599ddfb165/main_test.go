@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fixtureDir は testdata/<name> のパスを返す。
+// フィクスチャはリポジトリの go.work に含まれない独立したモジュールなので、ワークスペースモードを無効にする。
+func fixtureDir(t *testing.T, name string) string {
+	t.Helper()
+	t.Setenv("GOWORK", "off")
+	return filepath.Join("testdata", name)
+}
+
+// loadFixture は testdata/<name> のパッケージを読み込む
+func loadFixture(t *testing.T, name string) ([]*packages.Package, map[string]*packages.Package) {
+	t.Helper()
+	pkgs, pkgMap, err := loadPackages(fixtureDir(t, name), false)
+	if err != nil {
+		t.Fatalf("loading %s: %v", name, err)
+	}
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			t.Fatalf("fixture %s does not type-check: %v", name, e)
+		}
+	}
+	return pkgs, pkgMap
+}
+
+// runOutput はサブコマンドを実行し、標準出力に書かれた内容を返す
+func runOutput(t *testing.T, command string, args ...string) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+	runErr := runCommand(command, args)
+	os.Stdout = stdout
+	w.Close()
+	out := <-done
+	if runErr != nil {
+		t.Fatalf("%s %s: %v\n%s", command, strings.Join(args, " "), runErr, out)
+	}
+	return out
+}
+
+// assertContains は out に want の各行が含まれていることを確認する
+func assertContains(t *testing.T, out string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("output does not contain %q:\n%s", w, out)
+		}
+	}
+}
+
+// assertNotContains は out に unwanted の各行が含まれていないことを確認する
+func assertNotContains(t *testing.T, out string, unwanted ...string) {
+	t.Helper()
+	for _, u := range unwanted {
+		if strings.Contains(out, u) {
+			t.Errorf("output unexpectedly contains %q:\n%s", u, out)
+		}
+	}
+}
+
+// findEntry は名前でエントリーポイントを探す
+func findEntry(t *testing.T, eps []*EntryPoint, name string) *EntryPoint {
+	t.Helper()
+	var names []string
+	for _, ep := range eps {
+		if ep.Name == name {
+			return ep
+		}
+		names = append(names, ep.Name)
+	}
+	t.Fatalf("entry point %q not found (have %s)", name, strings.Join(names, ", "))
+	return nil
+}
+
+func TestGenericCalls(t *testing.T) {
+	pkgs, pkgMap := loadFixture(t, "generics")
+	ep := findEntry(t, collectEntryPoints(pkgs, pkgMap), "fixture/generics.main")
+
+	funcIDs := make(map[string]string) // ラベル → 解決できた関数 ID
+	for _, n := range buildCallTree(ep.Def.Node.Body, ep.Def.Package, pkgMap, make(map[string]bool)) {
+		funcIDs[n.Label] = n.FuncID
+	}
+	// インスタンス化されたジェネリック関数・メソッドも元の宣言に解決し、型引数を表示する
+	for label, id := range map[string]string{
+		"s.Push (main.Stack[int])": "fixture/generics.Stack.Push",
+		"Map[int, string]":         "fixture/generics.Map",
+	} {
+		if got, ok := funcIDs[label]; !ok || got != id {
+			t.Errorf("call %q resolved to %q (found: %v), want %q", label, got, ok, id)
+		}
+	}
+
+	reached := reachableFuncs(ep.Def, pkgMap)
+	if _, ok := reached["fixture/generics.format"]; !ok {
+		t.Error("format passed as a function value is not reachable from main")
+	}
+}
+
+// entrySection は "[kind] name" の見出しから次の見出しの直前までの出力を返す
+func entrySection(t *testing.T, out, header string) string {
+	t.Helper()
+	start := strings.Index(out, header+"\n")
+	if start < 0 {
+		t.Fatalf("output has no section %q:\n%s", header, out)
+	}
+	section := out[start+len(header)+1:]
+	if end := strings.Index(section, "\n["); end >= 0 {
+		section = section[:end+1]
+	}
+	return section
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// exitFuncs はプロセスを終了させる関数 (types.Func.FullName 形式)。recover では止められない。
+var exitFuncs = map[string]bool{
+	"os.Exit":               true,
+	"log.Fatal":             true,
+	"log.Fatalf":            true,
+	"log.Fatalln":           true,
+	"(*log.Logger).Fatal":   true,
+	"(*log.Logger).Fatalf":  true,
+	"(*log.Logger).Fatalln": true,
+}
+
+// panicFuncs は panic を起こす代表的な関数 (types.Func.FullName 形式)
+var panicFuncs = map[string]bool{
+	"log.Panic":               true,
+	"log.Panicf":              true,
+	"log.Panicln":             true,
+	"(*log.Logger).Panic":     true,
+	"(*log.Logger).Panicf":    true,
+	"(*log.Logger).Panicln":   true,
+	"regexp.MustCompile":      true,
+	"regexp.MustCompilePOSIX": true,
+	"text/template.Must":      true,
+	"html/template.Must":      true,
+}
+
+// runPanics は RPC / HTTP ハンドラから panic・log.Fatal・os.Exit などプロセスを落としうる箇所までの呼び出し経路を出力する
+//
+//	go run . panics -dir ./example
+func runPanics(args []string) error {
+	fset := flag.NewFlagSet("panics", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	fmt.Println("=== Crash sites reachable from request handlers ===")
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		if ep.Kind != EntryGRPC && ep.Kind != EntryHTTP {
+			continue
+		}
+		fmt.Printf("[%s] %s\n", ep.Kind, ep.Name)
+		paths := entryCallPaths(ep, pkgMap)
+		found := false
+		for _, fn := range sortedFuncs(entryReachableFuncs(ep, pkgMap)) {
+			for _, site := range crashSites(fn) {
+				found = true
+				fmt.Printf("  %s: %s\n", site.Pos, site.Desc)
+				fmt.Printf("    path: %s\n", pathString(paths[functionID(fn)]))
+				if site.Kind == crashPanic {
+					if rec := recoveringFunc(paths[functionID(fn)], site.Pos); rec != "" {
+						fmt.Printf("    (recovered by deferred recover in %s)\n", rec)
+					}
+				}
+			}
+		}
+		if !found {
+			fmt.Println("  (none)")
+		}
+	}
+	return nil
+}
+
+// クラッシュ箇所の種類
+const (
+	crashExit  = "exit"  // os.Exit / log.Fatal など。recover できない
+	crashPanic = "panic" // panic やランタイムパニック。経路上の defer recover() で止められる
+)
+
+// crashSite はプロセスを落としうる 1 箇所
+type crashSite struct {
+	Pos  token.Position
+	Kind string // crashExit / crashPanic
+	Desc string
+}
+
+// crashSites は fn の本体から panic / 終了関数の呼び出し、nil map への書き込み、
+// comma-ok を使わない型アサーションを探す
+func crashSites(fn *FunctionDefinition) []*crashSite {
+	if fn.Node.Body == nil {
+		return nil
+	}
+	typesInfo := fn.Package.TypesInfo
+	fset := fn.Package.Fset
+
+	// v, ok := x.(T) の形で使われている型アサーションは失敗しても panic しない
+	checked := make(map[*ast.TypeAssertExpr]bool)
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 2 && len(n.Rhs) == 1 {
+				if ta, ok := ast.Unparen(n.Rhs[0]).(*ast.TypeAssertExpr); ok {
+					checked[ta] = true
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == 2 && len(n.Values) == 1 {
+				if ta, ok := ast.Unparen(n.Values[0]).(*ast.TypeAssertExpr); ok {
+					checked[ta] = true
+				}
+			}
+		}
+		return true
+	})
+	nilMaps := nilMapVars(fn.Node.Body, typesInfo)
+
+	var sites []*crashSite
+	add := func(pos token.Pos, kind, format string, args ...interface{}) {
+		sites = append(sites, &crashSite{Pos: fset.Position(pos), Kind: kind, Desc: fmt.Sprintf(format, args...)})
+	}
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			switch obj := typesInfo.ObjectOf(getCallIdent(n)).(type) {
+			case *types.Builtin:
+				if obj.Name() == "panic" {
+					add(n.Pos(), crashPanic, "panic(%s)", exprListString(n.Args))
+				}
+			case *types.Func:
+				switch {
+				case exitFuncs[obj.FullName()]:
+					add(n.Pos(), crashExit, "%s terminates the process", obj.FullName())
+				case panicFuncs[obj.FullName()]:
+					add(n.Pos(), crashPanic, "%s may panic", obj.FullName())
+				}
+			}
+		case *ast.TypeAssertExpr:
+			if n.Type != nil && !checked[n] {
+				add(n.Pos(), crashPanic, "unchecked type assertion %s panics if the type does not match", types.ExprString(n))
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if ident := nilMapIndex(lhs, nilMaps, typesInfo); ident != nil {
+					add(lhs.Pos(), crashPanic, "write to nil map %s", ident.Name)
+				}
+			}
+		case *ast.IncDecStmt:
+			// m[k]++ も nil map への書き込みになる
+			if ident := nilMapIndex(n.X, nilMaps, typesInfo); ident != nil {
+				add(n.X.Pos(), crashPanic, "write to nil map %s", ident.Name)
+			}
+		}
+		return true
+	})
+	return sites
+}
+
+// nilMapIndex は書き込み先の式が nilMaps の変数への m[k] であれば、その変数の識別子を返す
+func nilMapIndex(expr ast.Expr, nilMaps map[types.Object]bool, typesInfo *types.Info) *ast.Ident {
+	index, ok := expr.(*ast.IndexExpr)
+	if !ok {
+		return nil
+	}
+	if ident, ok := index.X.(*ast.Ident); ok && nilMaps[typesInfo.Uses[ident]] {
+		return ident
+	}
+	return nil
+}
+
+// nilMapVars は var m map[K]V のように初期値なしで宣言され、その後一度も代入されていない map 型のローカル変数を返す
+func nilMapVars(body *ast.BlockStmt, typesInfo *types.Info) map[types.Object]bool {
+	nilMaps := make(map[types.Object]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || len(spec.Values) != 0 {
+			return true
+		}
+		for _, name := range spec.Names {
+			if obj := typesInfo.Defs[name]; obj != nil {
+				if _, isMap := obj.Type().Underlying().(*types.Map); isMap {
+					nilMaps[obj] = true
+				}
+			}
+		}
+		return true
+	})
+	// m = make(...) や &m のように代入・アドレス取得されていれば初期化されうるので対象外
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok {
+					delete(nilMaps, typesInfo.ObjectOf(ident))
+				}
+			}
+		case *ast.UnaryExpr:
+			if ident, ok := n.X.(*ast.Ident); ok && n.Op == token.AND {
+				delete(nilMaps, typesInfo.ObjectOf(ident))
+			}
+		}
+		return true
+	})
+	return nilMaps
+}
+
+// callPaths は def から到達できる各関数への最短の呼び出し経路 (def から順に並べた関数定義) を関数 ID をキーにして返す
+func callPaths(def *FunctionDefinition, pkgMap map[string]*packages.Package) map[string][]*FunctionDefinition {
+	paths := map[string][]*FunctionDefinition{functionID(def): {def}}
+	queue := []*FunctionDefinition{def}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		if fn.Node.Body == nil {
+			continue
+		}
+		ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			obj, ok := fn.Package.TypesInfo.Uses[ident].(*types.Func)
+			if !ok {
+				return true
+			}
+			callee := findFuncDecl(obj, pkgMap)
+			if callee == nil {
+				return true
+			}
+			if id := functionID(callee); paths[id] == nil {
+				path := append([]*FunctionDefinition{}, paths[functionID(fn)]...)
+				paths[id] = append(path, callee)
+				queue = append(queue, callee)
+			}
+			return true
+		})
+	}
+	return paths
+}
+
+// entryCallPaths は ep の起点から到達できる各関数への呼び出し経路を返す。
+// ミドルウェアは包んでいるハンドラを呼び出すものとして、外側のミドルウェアからの経路にする
+// (ミドルウェアの defer recover() がハンドラの panic を止める場合も recoveringFunc で分かるように)。
+func entryCallPaths(ep *EntryPoint, pkgMap map[string]*packages.Package) map[string][]*FunctionDefinition {
+	paths := make(map[string][]*FunctionDefinition)
+	var prefix []*FunctionDefinition
+	for _, root := range ep.roots() {
+		if p := paths[functionID(root)]; p != nil {
+			prefix = p[:len(p)-1] // 外側のミドルウェアから既にたどれている
+		}
+		for id, path := range callPaths(root, pkgMap) {
+			if paths[id] == nil {
+				paths[id] = append(append([]*FunctionDefinition{}, prefix...), path...)
+			}
+		}
+		prefix = paths[functionID(root)]
+	}
+	return paths
+}
+
+// pathString は呼び出し経路を "A -> B -> C" 形式の文字列にする
+func pathString(path []*FunctionDefinition) string {
+	names := make([]string, len(path))
+	for i, fn := range path {
+		names[i] = funcDeclName(fn.Node)
+	}
+	return strings.Join(names, " -> ")
+}
+
+// recoveringFunc は経路上で defer した関数リテラルから recover() を呼んでいる関数があれば、その表示名を返す。
+// panic が起きる関数自身 (経路の最後) は、panic の位置 site より前で defer している場合だけ数える。
+func recoveringFunc(path []*FunctionDefinition, site token.Position) string {
+	for i, fn := range path {
+		if fn.Node.Body == nil {
+			continue
+		}
+		pos := deferredRecover(fn.Node.Body, fn.Package.TypesInfo)
+		if !pos.IsValid() {
+			continue
+		}
+		if i == len(path)-1 && fn.Package.Fset.Position(pos).Offset > site.Offset {
+			continue
+		}
+		return funcDeclName(fn.Node)
+	}
+	return ""
+}
+
+// deferredRecover は body 内で最初に defer func() { ... recover() ... }() している位置を返す (なければ token.NoPos)
+func deferredRecover(body *ast.BlockStmt, typesInfo *types.Info) token.Pos {
+	var found token.Pos
+	ast.Inspect(body, func(n ast.Node) bool {
+		d, ok := n.(*ast.DeferStmt)
+		if !ok {
+			return !found.IsValid()
+		}
+		lit, ok := d.Call.Fun.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if b, ok := typesInfo.ObjectOf(getCallIdent(call)).(*types.Builtin); ok && b.Name() == "recover" {
+					found = d.Pos()
+				}
+			}
+			return !found.IsValid()
+		})
+		return !found.IsValid()
+	})
+	return found
+}
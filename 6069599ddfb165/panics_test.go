@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestPanicsExample(t *testing.T) {
+	out := runOutput(t, "panics")
+	assertContains(t, out, "[grpc] ExampleServer.Culc\n  (none)\n")
+}
+
+func TestPanicsFixture(t *testing.T) {
+	out := runOutput(t, "panics", "-dir", fixtureDir(t, "panics"))
+
+	assertContains(t, entrySection(t, out, "[http] /parse parseHandler"),
+		// m[k]++ も nil map への書き込みとして数える
+		"main.go:24:2: write to nil map counts\n    path: parseHandler -> mustPattern\n",
+		"main.go:25:9: regexp.MustCompile may panic\n    path: parseHandler -> mustPattern\n",
+	)
+
+	exit := entrySection(t, out, "[http] /exit exitHandler")
+	assertContains(t, exit, "main.go:29:2: log.Fatal terminates the process\n    path: exitHandler\n")
+	assertNotContains(t, exit, "recovered by")
+
+	// ミドルウェアの defer recover() が包んだハンドラの panic を止める
+	assertContains(t, entrySection(t, out, "[http] /safe safeHandler"),
+		"main.go:34:10: unchecked type assertion v.(string) panics if the type does not match\n"+
+			"    path: recoverMiddleware -> safeHandler\n"+
+			"    (recovered by deferred recover in recoverMiddleware)\n",
+	)
+
+	// comma-ok の型アサーションは panic しない
+	assertContains(t, entrySection(t, out, "[http] /ok okHandler"), "  (none)")
+}
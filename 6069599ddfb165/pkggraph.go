@@ -0,0 +1,327 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// 標準ライブラリのパッケージはまとめて 1 つのノードにする
+const stdNode = "std"
+
+// runPkgGraph は読み込んだモジュールのパッケージ間の import グラフを出力する。
+// 標準ライブラリは std に、外部パッケージはモジュール単位にまとめ、
+// 辺にはパッケージ間の呼び出しの数を重みとして付ける。
+//
+//	go run . pkggraph -dir ./example -format dot
+func runPkgGraph(args []string) error {
+	fset := flag.NewFlagSet("pkggraph", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	format := fset.String("format", "text", "output format: text or dot")
+	fset.Parse(args)
+
+	// テストの import による循環も検出したいので _test.go も含めて読み込む
+	pkgs, pkgMap, err := loadPackages(*dir, true)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	g := buildPkgGraph(pkgs, pkgMap)
+	switch *format {
+	case "text":
+		printPkgGraph(g)
+	case "dot":
+		fmt.Print(renderPkgGraphDot(g))
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	return nil
+}
+
+// pkgEdge はパッケージ間の import
+type pkgEdge struct {
+	From     string
+	To       string
+	Calls    int  // From のパッケージから To のパッケージの関数への呼び出しの数
+	TestOnly bool // _test.go からしか import されていない
+}
+
+// pkgGraph はパッケージ単位の依存グラフ
+type pkgGraph struct {
+	Modules map[string][]string // モジュールパス → 解析対象のパッケージパス
+	Edges   []*pkgEdge
+	Cycles  [][]string // 循環している解析対象のパッケージの組
+}
+
+// buildPkgGraph は解析対象パッケージの import と呼び出しからパッケージ単位の依存グラフを組み立てる
+func buildPkgGraph(pkgs []*packages.Package, pkgMap map[string]*packages.Package) *pkgGraph {
+	g := &pkgGraph{Modules: make(map[string][]string)}
+	edges := make(map[[2]string]*pkgEdge)
+	// 直接 import していないパッケージ (grpc/internal/status など) のメソッドも、所属するモジュールのノードに数えるため
+	allPkgs := make(map[string]*packages.Package)
+	packages.Visit(pkgs, nil, func(p *packages.Package) { allPkgs[p.PkgPath] = p })
+	edge := func(from, to string) *pkgEdge {
+		key := [2]string{from, to}
+		if edges[key] == nil {
+			edges[key] = &pkgEdge{From: from, To: to, TestOnly: true}
+		}
+		return edges[key]
+	}
+
+	for _, pkg := range pkgs {
+		module := packageModule(pkg)
+		g.Modules[module] = append(g.Modules[module], pkg.PkgPath)
+
+		for _, file := range pkg.Syntax {
+			isTest := strings.HasSuffix(pkg.Fset.Position(file.Pos()).Filename, "_test.go")
+			for _, spec := range file.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil || path == "C" {
+					continue
+				}
+				to := pkgNodeName(path, pkg.Imports[path], pkgMap)
+				if to == pkg.PkgPath {
+					continue
+				}
+				e := edge(pkg.PkgPath, to)
+				e.TestOnly = e.TestOnly && isTest
+			}
+
+			// 呼び出し先の関数が属するパッケージごとに呼び出しを数える
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				obj := calledFunc(call, pkg.TypesInfo)
+				if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() == pkg.PkgPath {
+					return true
+				}
+				to := pkgNodeName(obj.Pkg().Path(), allPkgs[obj.Pkg().Path()], pkgMap)
+				if e := edges[[2]string{pkg.PkgPath, to}]; e != nil {
+					e.Calls++
+				}
+				return true
+			})
+		}
+	}
+
+	for _, e := range edges {
+		g.Edges = append(g.Edges, e)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	for _, paths := range g.Modules {
+		sort.Strings(paths)
+	}
+	g.Cycles = findImportCycles(g.Edges, pkgMap)
+	return g
+}
+
+// calledFunc は呼び出し先として静的に解決できる関数・メソッドを返す。
+// 型変換 (lib.Celsius(x))・組み込み関数・関数型の変数やフィールド (メソッド値を代入した変数を含む) の呼び出しは nil を返す。
+func calledFunc(call *ast.CallExpr, typesInfo *types.Info) *types.Func {
+	fun := ast.Unparen(call.Fun)
+	if tv, ok := typesInfo.Types[fun]; ok && tv.IsType() {
+		return nil
+	}
+	var obj types.Object
+	switch f := ast.Unparen(unwrapIndexExpr(fun)).(type) {
+	case *ast.Ident:
+		obj = typesInfo.Uses[f]
+	case *ast.SelectorExpr:
+		if sel := typesInfo.Selections[f]; sel != nil {
+			if sel.Kind() == types.FieldVal {
+				return nil
+			}
+			obj = sel.Obj()
+		} else {
+			obj = typesInfo.Uses[f.Sel] // パッケージ修飾の関数
+		}
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	return fn.Origin()
+}
+
+// pkgNodeName は import パスをグラフのノード名にする。
+// 解析対象のパッケージはそのまま、標準ライブラリは std、それ以外は所属するモジュールのパスにまとめる。
+func pkgNodeName(path string, imported *packages.Package, pkgMap map[string]*packages.Package) string {
+	if pkgMap[path] != nil {
+		return path
+	}
+	if imported != nil && imported.Module != nil {
+		return imported.Module.Path
+	}
+	if first, _, _ := strings.Cut(path, "/"); !strings.Contains(first, ".") {
+		return stdNode // ドメインを含まないパスは標準ライブラリとみなす
+	}
+	return path
+}
+
+// findImportCycles は解析対象パッケージ間の import の循環を強連結成分として探す (Tarjan のアルゴリズム)。
+// 外部パッケージから解析対象のパッケージへ戻る import はないため、解析対象のパッケージだけを調べればよい。
+func findImportCycles(edges []*pkgEdge, pkgMap map[string]*packages.Package) [][]string {
+	adj := make(map[string][]string)
+	var nodes []string
+	for _, e := range edges {
+		if pkgMap[e.From] != nil && pkgMap[e.To] != nil {
+			adj[e.From] = append(adj[e.From], e.To)
+		}
+	}
+	for path := range pkgMap {
+		nodes = append(nodes, path)
+	}
+	sort.Strings(nodes)
+
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range adj[v] {
+			if _, seen := index[w]; !seen {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+		if lowlink[v] != index[v] {
+			return
+		}
+		var scc []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		if len(scc) > 1 {
+			sort.Strings(scc)
+			cycles = append(cycles, scc)
+		}
+	}
+	for _, v := range nodes {
+		if _, seen := index[v]; !seen {
+			visit(v)
+		}
+	}
+	return cycles
+}
+
+// cycleThroughTests は循環に含まれる辺のうち、_test.go からの import だけで成り立っているものを返す。
+// 空でなければ go test の時だけ発生する循環 (import cycle not allowed in test) になる。
+func cycleThroughTests(cycle []string, edges []*pkgEdge) []*pkgEdge {
+	in := make(map[string]bool)
+	for _, path := range cycle {
+		in[path] = true
+	}
+	var testEdges []*pkgEdge
+	for _, e := range edges {
+		if in[e.From] && in[e.To] && e.TestOnly {
+			testEdges = append(testEdges, e)
+		}
+	}
+	return testEdges
+}
+
+// printPkgGraph はモジュールごとのパッケージ、import の辺と呼び出し数、循環をテキストで出力する
+func printPkgGraph(g *pkgGraph) {
+	fmt.Println("=== Package dependency graph ===")
+	var modules []string
+	for module := range g.Modules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		fmt.Printf("[module] %s\n", module)
+		for _, path := range g.Modules[module] {
+			fmt.Printf("  %s\n", path)
+			for _, e := range g.Edges {
+				if e.From != path {
+					continue
+				}
+				note := ""
+				if e.TestOnly {
+					note = " (test only)"
+				}
+				fmt.Printf("    -> %s [%d calls]%s\n", e.To, e.Calls, note)
+			}
+		}
+	}
+
+	fmt.Println("\n=== Import cycles ===")
+	if len(g.Cycles) == 0 {
+		fmt.Println("(none)")
+	}
+	for _, cycle := range g.Cycles {
+		fmt.Printf("cycle: %s\n", strings.Join(cycle, " <-> "))
+		for _, e := range cycleThroughTests(cycle, g.Edges) {
+			fmt.Printf("  WARNING: %s -> %s is imported only from _test.go; go test fails with an import cycle\n", e.From, e.To)
+		}
+	}
+}
+
+// renderPkgGraphDot は Graphviz の dot 形式で出力する。モジュールは subgraph cluster で囲む。
+func renderPkgGraphDot(g *pkgGraph) string {
+	var sb strings.Builder
+	sb.WriteString("digraph packages {\n")
+	sb.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+
+	var modules []string
+	for module := range g.Modules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for i, module := range modules {
+		fmt.Fprintf(&sb, "  subgraph cluster_%d {\n    label=%q;\n", i, module)
+		for _, path := range g.Modules[module] {
+			fmt.Fprintf(&sb, "    %q;\n", path)
+		}
+		sb.WriteString("  }\n")
+	}
+
+	cycleOf := make(map[string]int) // パッケージパス → 含まれる循環の番号 + 1
+	for i, cycle := range g.Cycles {
+		for _, path := range cycle {
+			cycleOf[path] = i + 1
+		}
+	}
+	for _, e := range g.Edges {
+		attrs := []string{fmt.Sprintf("label=%q", strconv.Itoa(e.Calls))}
+		if e.Calls > 0 {
+			attrs = append(attrs, fmt.Sprintf("penwidth=%d", min(1+e.Calls/5, 8)))
+		}
+		if e.TestOnly {
+			attrs = append(attrs, "style=dashed")
+		}
+		if cycleOf[e.From] != 0 && cycleOf[e.From] == cycleOf[e.To] {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(&sb, "  %q -> %q [%s];\n", e.From, e.To, strings.Join(attrs, ", "))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
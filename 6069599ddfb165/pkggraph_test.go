@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestPkgGraphExample(t *testing.T) {
+	out := runOutput(t, "pkggraph")
+	assertContains(t, out,
+		"[module] github.com/shunta-furukawa/zenn-demo/6069599ddfb165/example\n",
+		"    -> github.com/shunta-furukawa/zenn-demo/6069599ddfb165/example/server [3 calls]\n",
+		"=== Import cycles ===\n(none)\n",
+	)
+}
+
+func TestPkgGraphFixture(t *testing.T) {
+	dir := fixtureDir(t, "pkggraph")
+	out := runOutput(t, "pkggraph", "-dir", dir)
+	assertContains(t, out,
+		// Parse・(lib.Parse)・c.String の 3 回だけを数え、型変換・メソッド値を代入した変数・関数型のフィールドの呼び出しは数えない
+		"  fixture/pkggraph/app\n    -> fixture/pkggraph/lib [3 calls]\n",
+		"    -> fixture/pkggraph/app [1 calls] (test only)\n",
+		"cycle: fixture/pkggraph/app <-> fixture/pkggraph/lib\n",
+		"WARNING: fixture/pkggraph/lib -> fixture/pkggraph/app is imported only from _test.go",
+	)
+
+	dot := runOutput(t, "pkggraph", "-dir", dir, "-format", "dot")
+	assertContains(t, dot,
+		`"fixture/pkggraph/app" -> "fixture/pkggraph/lib" [label="3", penwidth=1, color=red];`,
+		`"fixture/pkggraph/lib" -> "fixture/pkggraph/app" [label="1", penwidth=1, style=dashed, color=red];`,
+	)
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ProtoFile は .proto ファイルから読み取ったサービスとメッセージの定義
+type ProtoFile struct {
+	Path      string
+	Package   string
+	GoPackage string // option go_package の import パス (";" 以降のパッケージ名は除く)
+	Services  []*ProtoService
+	Messages  []*ProtoMessage // ネストしたメッセージも Outer.Inner の名前で平坦に格納する
+}
+
+// ProtoService は service 定義
+type ProtoService struct {
+	Name    string
+	Line    int
+	Methods []*ProtoMethod
+}
+
+// ProtoMethod は rpc 定義
+type ProtoMethod struct {
+	Name         string
+	Line         int
+	Request      string // リクエストメッセージ名 (パッケージ修飾は除く)
+	Response     string // レスポンスメッセージ名
+	ClientStream bool
+	ServerStream bool
+}
+
+// ProtoMessage は message 定義
+type ProtoMessage struct {
+	Name   string // ネストしている場合は Outer.Inner
+	Line   int
+	Fields []*ProtoField
+}
+
+// ProtoField は message のフィールド定義
+type ProtoField struct {
+	Name   string
+	Number string
+	Line   int
+	Oneof  string // oneof のメンバーであれば oneof の名前
+}
+
+// findProtoFiles は dir 配下の .proto ファイルを探す (隠しディレクトリと vendor は除く)。
+// dir が go.work ファイルの場合は use されている各モジュールの配下を探す。
+func findProtoFiles(dir string) ([]string, error) {
+	roots := []string{dir}
+	if isWorkFile(dir) {
+		var err error
+		if roots, err = workspaceModules(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	seen := make(map[string]bool) // 入れ子になったモジュールで同じファイルを重複して数えないため
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".proto") && !seen[path] {
+				seen[path] = true
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// parseProtoFile は .proto ファイルを読み込んで解析する
+func parseProtoFile(path string) (*ProtoFile, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokenizeProto(string(src))}
+	file := &ProtoFile{Path: path}
+	if err := p.parseFile(file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Message は名前 (パッケージ修飾は除く) に対応するメッセージ定義を返す
+func (f *ProtoFile) Message(name string) *ProtoMessage {
+	name = strings.TrimPrefix(name, f.Package+".")
+	for _, m := range f.Messages {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// protoToken は .proto のトークン (識別子・記号・文字列) と行番号
+type protoToken struct {
+	text string
+	line int
+}
+
+// tokenizeProto はコメントを取り除きながら .proto の本文をトークンに分割する
+func tokenizeProto(src string) []protoToken {
+	var tokens []protoToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			tokens = append(tokens, protoToken{text: src[i:min(j+1, len(src))], line: line})
+			i = j + 1
+		case isProtoIdentChar(rune(c)):
+			j := i
+			for j < len(src) && isProtoIdentChar(rune(src[j])) {
+				j++
+			}
+			tokens = append(tokens, protoToken{text: src[i:j], line: line})
+			i = j
+		default:
+			tokens = append(tokens, protoToken{text: string(c), line: line})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isProtoIdentChar(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// protoParser は service / message / rpc / フィールドだけを読み取る簡易パーサ。
+// option や enum などの解析に不要な定義は読み飛ばす。
+type protoParser struct {
+	tokens []protoToken
+	pos    int
+}
+
+func (p *protoParser) peek() protoToken {
+	if p.pos >= len(p.tokens) {
+		return protoToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() protoToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *protoParser) expect(text string) error {
+	if t := p.next(); t.text != text {
+		return fmt.Errorf("line %d: expected %q but got %q", t.line, text, t.text)
+	}
+	return nil
+}
+
+// skipStatement は次の ";" まで、または { } ブロックの終わりまで読み飛ばす
+func (p *protoParser) skipStatement() {
+	for p.pos < len(p.tokens) {
+		switch p.next().text {
+		case ";":
+			return
+		case "{":
+			p.skipBlock()
+			return
+		}
+	}
+}
+
+// skipBlock は "{" の直後から対応する "}" まで読み飛ばす
+func (p *protoParser) skipBlock() {
+	depth := 1
+	for p.pos < len(p.tokens) && depth > 0 {
+		switch p.next().text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+}
+
+func (p *protoParser) parseFile(file *ProtoFile) error {
+	for p.pos < len(p.tokens) {
+		switch p.peek().text {
+		case "package":
+			p.next()
+			file.Package = p.next().text
+			p.skipStatement()
+		case "option":
+			p.next()
+			if name := p.next(); name.text == "go_package" && p.peek().text == "=" {
+				p.next()
+				value, _ := strconv.Unquote(p.next().text)
+				file.GoPackage, _, _ = strings.Cut(value, ";")
+			}
+			p.skipStatement()
+		case "service":
+			svc, err := p.parseService()
+			if err != nil {
+				return err
+			}
+			file.Services = append(file.Services, svc)
+		case "message":
+			msgs, err := p.parseMessage("")
+			if err != nil {
+				return err
+			}
+			file.Messages = append(file.Messages, msgs...)
+		default:
+			p.skipStatement()
+		}
+	}
+	return nil
+}
+
+func (p *protoParser) parseService() (*ProtoService, error) {
+	p.next() // service
+	name := p.next()
+	svc := &ProtoService{Name: name.text, Line: name.line}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) {
+		switch p.peek().text {
+		case "}":
+			p.next()
+			return svc, nil
+		case "rpc":
+			m, err := p.parseRPC()
+			if err != nil {
+				return nil, err
+			}
+			svc.Methods = append(svc.Methods, m)
+		default:
+			p.skipStatement()
+		}
+	}
+	return nil, fmt.Errorf("service %s: unexpected end of file", svc.Name)
+}
+
+// parseRPC は rpc Name (stream? Req) returns (stream? Res) { ... } / ; を読む
+func (p *protoParser) parseRPC() (*ProtoMethod, error) {
+	p.next() // rpc
+	name := p.next()
+	m := &ProtoMethod{Name: name.text, Line: name.line}
+	var err error
+	if m.Request, m.ClientStream, err = p.parseRPCType(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("returns"); err != nil {
+		return nil, err
+	}
+	if m.Response, m.ServerStream, err = p.parseRPCType(); err != nil {
+		return nil, err
+	}
+	p.skipStatement()
+	return m, nil
+}
+
+func (p *protoParser) parseRPCType() (string, bool, error) {
+	if err := p.expect("("); err != nil {
+		return "", false, err
+	}
+	stream := false
+	if p.peek().text == "stream" {
+		p.next()
+		stream = true
+	}
+	typ := strings.TrimPrefix(p.next().text, ".")
+	return typ, stream, p.expect(")")
+}
+
+// parseMessage は message を読み、ネストしたメッセージも含めて返す
+func (p *protoParser) parseMessage(parent string) ([]*ProtoMessage, error) {
+	p.next() // message
+	name := p.next()
+	msg := &ProtoMessage{Name: name.text, Line: name.line}
+	if parent != "" {
+		msg.Name = parent + "." + name.text
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	msgs := []*ProtoMessage{msg}
+	for p.pos < len(p.tokens) {
+		switch t := p.peek(); t.text {
+		case "}":
+			p.next()
+			return msgs, nil
+		case "message":
+			nested, err := p.parseMessage(msg.Name)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, nested...)
+		case "oneof":
+			// oneof の中のフィールドは親メッセージのフィールドとして扱い、どの oneof に属するかを記録する
+			p.next()
+			oneof := p.next().text
+			if err := p.expect("{"); err != nil {
+				return nil, err
+			}
+			for p.pos < len(p.tokens) && p.peek().text != "}" {
+				if p.peek().text == "option" {
+					p.skipStatement()
+				} else if f := p.parseField(); f != nil {
+					f.Oneof = oneof
+					msg.Fields = append(msg.Fields, f)
+				}
+			}
+			p.next() // oneof の }
+		case "enum", "option", "reserved", "extensions", "extend":
+			p.skipStatement()
+		case ";":
+			p.next()
+		default:
+			if f := p.parseField(); f != nil {
+				msg.Fields = append(msg.Fields, f)
+			}
+		}
+	}
+	return nil, fmt.Errorf("message %s: unexpected end of file", msg.Name)
+}
+
+// parseField は [optional|repeated] type name = number [options]; を読む。
+// map<K, V> 型にも対応する。フィールドとして読めない場合は文を読み飛ばして nil を返す。
+func (p *protoParser) parseField() *ProtoField {
+	start := p.pos
+	var words []protoToken
+	for p.pos < len(p.tokens) {
+		t := p.next()
+		switch t.text {
+		case "=":
+			number := p.next().text
+			p.skipStatement()
+			if len(words) == 0 {
+				return nil
+			}
+			name := words[len(words)-1]
+			return &ProtoField{Name: name.text, Number: number, Line: name.line}
+		case ";", "{", "}":
+			p.pos = start
+			p.skipStatement()
+			return nil
+		case "<", ">", ",":
+			// map<K, V> の型部分
+		default:
+			words = append(words, t)
+		}
+	}
+	return nil
+}
+
+// goCamelCase は protoc-gen-go (protogen.GoCamelCase) と同様に proto の名前を Go の識別子に変換する
+// (foo_bar → FooBar, address_2_line → Address_2Line, ネストしたメッセージ Outer.Inner → Outer_Inner)
+func goCamelCase(s string) string {
+	isLower := func(c byte) bool { return 'a' <= c && c <= 'z' }
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isLower(s[i+1]):
+			// ".小文字" の "." は読み飛ばす
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X') // 先頭の "_" は "X" にする
+		case c == '_' && i+1 < len(s) && isLower(s[i+1]):
+			// "_小文字" の "_" は読み飛ばす
+		case '0' <= c && c <= '9':
+			b = append(b, c)
+		default:
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			// 続く小文字はそのまま使う
+			for ; i+1 < len(s) && isLower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// parseProtoSource は .proto の本文を解析する
+func parseProtoSource(t *testing.T, src string) *ProtoFile {
+	t.Helper()
+	p := &protoParser{tokens: tokenizeProto(src)}
+	file := &ProtoFile{Path: "test.proto"}
+	if err := p.parseFile(file); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return file
+}
+
+// fieldNames はメッセージのフィールドを "name=number@line" の形で返す
+func fieldNames(t *testing.T, file *ProtoFile, msg string) []string {
+	t.Helper()
+	m := file.Message(msg)
+	if m == nil {
+		t.Fatalf("message %s not found", msg)
+	}
+	var names []string
+	for _, f := range m.Fields {
+		names = append(names, f.Name+"="+f.Number+"@"+itoa(f.Line))
+	}
+	return names
+}
+
+func itoa(n int) string {
+	return string(rune('0'+n/10)) + string(rune('0'+n%10))
+}
+
+func TestParseProtoServices(t *testing.T) {
+	file := parseProtoSource(t, `syntax = "proto3";
+package shop.v1;
+option go_package = "example.com/shop/v1;shopv1";
+import "google/protobuf/empty.proto";
+
+service Shop {
+  option (custom) = true;
+  rpc GetItem(GetItemRequest) returns (.shop.v1.Item);
+  rpc Watch(stream WatchRequest) returns (stream Event) {
+    option deprecated = true;
+  }
+}
+`)
+	if file.Package != "shop.v1" || file.GoPackage != "example.com/shop/v1" {
+		t.Errorf("package = %q, go_package = %q", file.Package, file.GoPackage)
+	}
+	if len(file.Services) != 1 || len(file.Services[0].Methods) != 2 {
+		t.Fatalf("services = %+v", file.Services)
+	}
+	get, watch := file.Services[0].Methods[0], file.Services[0].Methods[1]
+	if get.Request != "GetItemRequest" || get.Response != "shop.v1.Item" || get.ClientStream || get.ServerStream || get.Line != 8 {
+		t.Errorf("GetItem = %+v", get)
+	}
+	if watch.Request != "WatchRequest" || watch.Response != "Event" || !watch.ClientStream || !watch.ServerStream {
+		t.Errorf("Watch = %+v", watch)
+	}
+}
+
+func TestParseProtoMessages(t *testing.T) {
+	file := parseProtoSource(t, `syntax = "proto3";
+package shop;
+
+// Item はコメントの中の message Fake { int32 x = 1; } を無視する
+message Item {
+  /* ブロックコメント
+     string fake = 9; */
+  string name = 1; // 行末のコメント
+  map<string, int64> prices = 2;
+  repeated string tags = 3 [packed = true];
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+  }
+  Kind kind = 4;
+  reserved 5, 6;
+
+  message Variant {
+    string sku = 1;
+    message Size {
+      int32 cm = 1;
+    }
+  }
+  optional Variant variant = 7;
+}
+`)
+	if got, want := fieldNames(t, file, "Item"), []string{"name=1@08", "prices=2@09", "tags=3@10", "kind=4@14", "variant=7@23"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Item fields = %v, want %v", got, want)
+	}
+	if got, want := fieldNames(t, file, "shop.Item.Variant"), []string{"sku=1@18"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Item.Variant fields = %v, want %v", got, want)
+	}
+	if got, want := fieldNames(t, file, "Item.Variant.Size"), []string{"cm=1@20"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Item.Variant.Size fields = %v, want %v", got, want)
+	}
+	if file.Message("Fake") != nil {
+		t.Error("message in a comment was parsed")
+	}
+}
+
+func TestParseProtoOneof(t *testing.T) {
+	file := parseProtoSource(t, `syntax = "proto3";
+message SearchRequest {
+  oneof query {
+    option (validate.required) = true;
+    string text = 1;
+    int64 user_id = 2;
+  }
+  int32 limit = 3;
+}
+`)
+	m := file.Message("SearchRequest")
+	if m == nil || len(m.Fields) != 3 {
+		t.Fatalf("SearchRequest = %+v", m)
+	}
+	for i, want := range []struct{ name, oneof string }{{"text", "query"}, {"user_id", "query"}, {"limit", ""}} {
+		if f := m.Fields[i]; f.Name != want.name || f.Oneof != want.oneof {
+			t.Errorf("field %d = %s (oneof %q), want %s (oneof %q)", i, f.Name, f.Oneof, want.name, want.oneof)
+		}
+	}
+}
+
+func TestGoCamelCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"page_size":       "PageSize",
+		"next_page_token": "NextPageToken",
+		"Item.Variant":    "Item_Variant",
+		"address_2_line":  "Address_2Line",
+		"http2_push":      "Http2Push",
+		"GetItemRequest":  "GetItemRequest",
+		"v1beta_item":     "V1BetaItem",
+		"_private":        "XPrivate",
+	} {
+		if got := goCamelCase(in); got != want {
+			t.Errorf("goCamelCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"html"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// reportTemplate は report コマンドが出力する HTML のテンプレート
+//
+//go:embed templates/report.html
+var reportTemplate string
+
+// runReport は go tool cover -html のように、モジュール内のソースファイルを 1 つの HTML に書き出す。
+// 選択したエントリーポイントから到達できる関数を色付けし、呼び出し箇所は呼び出し先の定義へのリンクにする。
+//
+//	go run . report -dir ./example -o report.html
+func runReport(args []string) error {
+	fset := flag.NewFlagSet("report", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	out := fset.String("o", "report.html", "output HTML file")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+	data, err := buildReport(pkgs, pkgMap)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tmpl.Execute(f, data); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", *out)
+	return nil
+}
+
+// reportData は HTML テンプレートに渡すデータ
+type reportData struct {
+	Files   []*reportFile
+	Entries []*reportEntry
+}
+
+// reportFile は HTML に変換した 1 つのソースファイル
+type reportFile struct {
+	ID     string        // アンカー名
+	Name   string        // 表示名 (ファイルパス)
+	Source template.HTML // 関数の範囲と呼び出しのリンクを埋め込んだソース
+}
+
+// reportEntry はエントリーポイントと、そこから到達できる関数のアンカー名
+type reportEntry struct {
+	Label string
+	Funcs []string
+}
+
+// buildReport は全ファイルのソースを HTML に変換し、エントリーポイントごとの到達関数を集める
+func buildReport(pkgs []*packages.Package, pkgMap map[string]*packages.Package) (*reportData, error) {
+	// 関数宣言 → アンカー名 (fn-0, fn-1, ...)
+	anchors := make(map[*ast.FuncDecl]string)
+	var files []*ast.File
+	fileOf := make(map[*ast.File]*packages.Package)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			files = append(files, file)
+			fileOf[file] = pkg
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok {
+					anchors[fn] = fmt.Sprintf("fn-%d", len(anchors))
+				}
+			}
+		}
+	}
+
+	data := &reportData{}
+	for i, file := range files {
+		pkg := fileOf[file]
+		name := pkg.Fset.Position(file.Pos()).Filename
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		data.Files = append(data.Files, &reportFile{
+			ID:     fmt.Sprintf("file-%d", i),
+			Name:   name,
+			Source: annotateSource(src, file, pkg, pkgMap, anchors),
+		})
+	}
+	sort.Slice(data.Files, func(i, j int) bool { return data.Files[i].Name < data.Files[j].Name })
+
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		entry := &reportEntry{Label: fmt.Sprintf("[%s] %s", ep.Kind, ep.Name)}
+		for _, fn := range sortedFuncs(entryReachableFuncs(ep, pkgMap)) {
+			entry.Funcs = append(entry.Funcs, anchors[fn.Node])
+		}
+		data.Entries = append(data.Entries, entry)
+	}
+	return data, nil
+}
+
+// sourceInsert はソースのバイト位置に差し込む HTML タグ
+type sourceInsert struct {
+	offset int
+	order  int // 同じ位置では閉じタグ (0) を開きタグ (1) より先に出す
+	tag    string
+}
+
+// annotateSource はソースを HTML エスケープし、関数宣言を <span class="fn"> で囲み、
+// 解決できた呼び出し先の識別子を定義へのリンクにする
+func annotateSource(src []byte, file *ast.File, pkg *packages.Package, pkgMap map[string]*packages.Package, anchors map[*ast.FuncDecl]string) template.HTML {
+	tokFile := pkg.Fset.File(file.Pos())
+
+	var inserts []sourceInsert
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		anchor := anchors[fn]
+		inserts = append(inserts,
+			sourceInsert{tokFile.Offset(fn.Pos()), 1, fmt.Sprintf(`<span class="fn" id="%s" data-fn="%s">`, anchor, anchor)},
+			sourceInsert{tokFile.Offset(fn.End()), 0, `</span>`},
+		)
+		if fn.Body == nil {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			ident := getCallIdent(call)
+			obj, ok := pkg.TypesInfo.ObjectOf(ident).(*types.Func)
+			if !ok {
+				return true
+			}
+			callee := findFuncDecl(obj, pkgMap)
+			if callee == nil {
+				return true
+			}
+			inserts = append(inserts,
+				sourceInsert{tokFile.Offset(ident.Pos()), 1, fmt.Sprintf(`<a href="#%s" title="%s">`, anchors[callee.Node], html.EscapeString(functionID(callee)))},
+				sourceInsert{tokFile.Offset(ident.End()), 0, `</a>`},
+			)
+			return true
+		})
+	}
+	sort.SliceStable(inserts, func(i, j int) bool {
+		if inserts[i].offset != inserts[j].offset {
+			return inserts[i].offset < inserts[j].offset
+		}
+		return inserts[i].order < inserts[j].order
+	})
+
+	var sb strings.Builder
+	prev := 0
+	for _, ins := range inserts {
+		sb.WriteString(html.EscapeString(string(src[prev:ins.offset])))
+		sb.WriteString(ins.tag)
+		prev = ins.offset
+	}
+	sb.WriteString(html.EscapeString(string(src[prev:])))
+	return template.HTML(sb.String())
+}
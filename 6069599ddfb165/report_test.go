@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReportExample(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	runOutput(t, "report", "-o", out)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(data),
+		"[grpc] ExampleServer.Culc",
+		`title="github.com/shunta-furukawa/zenn-demo/6069599ddfb165/example/server.CulcService.Multiply"`,
+	)
+}
+
+func TestBuildReportFixture(t *testing.T) {
+	pkgs, pkgMap := loadFixture(t, "httptests")
+	data, err := buildReport(pkgs, pkgMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Files) != 1 || !strings.HasSuffix(data.Files[0].Name, filepath.Join("httptests", "main.go")) {
+		t.Fatalf("files = %+v", data.Files)
+	}
+	source := string(data.Files[0].Source)
+
+	// 関数宣言ごとのアンカー名を、ソースに埋め込まれた <span class="fn" id="..."> の順 (宣言順) から求める
+	anchors := make(map[string]string)
+	for _, name := range []string{"main", "helloHandler", "greeting", "adminHandler", "requireAdmin"} {
+		i := strings.Index(source, "func "+name+"(")
+		start := strings.LastIndex(source[:i], `<span class="fn" id="`)
+		if i < 0 || start < 0 {
+			t.Fatalf("function %s is not wrapped in a span:\n%s", name, source)
+		}
+		id := source[start+len(`<span class="fn" id="`):]
+		anchors[name] = id[:strings.Index(id, `"`)]
+	}
+
+	// 呼び出し先は定義へのリンクになり、ソースは HTML エスケープされる
+	assertContains(t, source,
+		`w.Write([]byte(<a href="#`+anchors["greeting"]+`" title="fixture/httptests.greeting">greeting</a>()))`,
+		`func helloHandler(w http.ResponseWriter, r *http.Request)`,
+		`if r.Header.Get(&#34;X-Admin&#34;) == &#34;&#34; {`,
+	)
+
+	funcs := make(map[string][]string)
+	for _, e := range data.Entries {
+		funcs[e.Label] = e.Funcs
+	}
+	hello := strings.Join(funcs["[http] /hello helloHandler"], " ")
+	if want := anchors["greeting"] + " " + anchors["helloHandler"]; hello != want { // 関数 ID 順
+		t.Errorf("/hello reaches %q, want %q", hello, want)
+	}
+	if admin := funcs["[http] /admin adminHandler"]; len(admin) == 0 {
+		t.Errorf("entries = %v, want an /admin entry", funcs)
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// runSequence は指定した RPC の呼び出し順をシーケンス図 (PlantUML / Mermaid) として出力する
+//
+//	go run . sequence -rpc ExampleServer.Culc -format mermaid
+func runSequence(args []string) error {
+	fset := flag.NewFlagSet("sequence", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	rpc := fset.String("rpc", "", "RPC to render (e.g. ExampleServer.Culc or Culc)")
+	format := fset.String("format", "plantuml", "output format: plantuml or mermaid")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	var names []string
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		if ep.Kind != EntryGRPC {
+			continue
+		}
+		names = append(names, ep.Name)
+		if ep.Name != *rpc && ep.Def.Name != *rpc {
+			continue
+		}
+
+		b := &sequenceBuilder{pkgMap: pkgMap, inProgress: make(map[string]bool)}
+		root := participantName(ep.Def)
+		b.add(seqEvent{Kind: seqCall, From: "Client", To: root, Label: ep.Def.Name})
+		b.walkFunc(ep.Def, root)
+		b.add(seqEvent{Kind: seqReturn, From: root, To: "Client"})
+
+		switch *format {
+		case "plantuml":
+			fmt.Print(renderPlantUML(b.events))
+		case "mermaid":
+			fmt.Print(renderMermaid(b.events))
+		default:
+			return fmt.Errorf("unknown format: %s", *format)
+		}
+		return nil
+	}
+	return fmt.Errorf("RPC %q not found (available: %s)", *rpc, strings.Join(names, ", "))
+}
+
+// シーケンス図のイベントの種類
+const (
+	seqCall   = "call"   // From → To の呼び出し
+	seqAsync  = "async"  // From → To の非同期の呼び出し (go 文)。戻りの矢印はない
+	seqReturn = "return" // To への戻り
+	seqNote   = "note"   // From の上の注釈
+	seqLoop   = "loop"   // ループブロックの開始
+	seqAlt    = "alt"    // 条件分岐ブロックの開始
+	seqElse   = "else"   // 条件分岐の次の分岐
+	seqPar    = "par"    // 並行に実行されるブロック (goroutine) の開始
+	seqEnd    = "end"    // ブロックの終了
+)
+
+// seqEvent はシーケンス図の 1 要素
+type seqEvent struct {
+	Kind  string
+	From  string
+	To    string
+	Label string
+}
+
+// sequenceBuilder は関数本体の文を順にたどり、シーケンス図のイベント列を組み立てる
+type sequenceBuilder struct {
+	pkgMap     map[string]*packages.Package
+	events     []seqEvent
+	inProgress map[string]bool  // 再帰呼び出しで無限にたどらないよう、展開中の関数 ID を記録
+	defers     [][]deferredCall // 実行中の関数本体ごとの defer された呼び出し (関数の終了時に逆順に出力する)
+}
+
+// deferredCall は defer 文で登録された呼び出し。引数は defer 文の時点で評価済み。
+type deferredCall struct {
+	call      *ast.CallExpr
+	typesInfo *types.Info
+}
+
+func (b *sequenceBuilder) add(ev seqEvent) {
+	b.events = append(b.events, ev)
+}
+
+// walkFunc は関数本体をたどる。self はこの関数を実行している参加者 (レシーバ型名)。
+func (b *sequenceBuilder) walkFunc(fn *FunctionDefinition, self string) {
+	id := functionID(fn)
+	if b.inProgress[id] || fn.Node.Body == nil {
+		return
+	}
+	b.inProgress[id] = true
+	defer delete(b.inProgress, id)
+
+	b.walkBody(fn.Node.Body.List, fn.Package.TypesInfo, self)
+}
+
+// walkBody は関数 (または関数リテラル) の本体をたどり、最後に defer された呼び出しを登録と逆の順で出力する
+func (b *sequenceBuilder) walkBody(stmts []ast.Stmt, typesInfo *types.Info, self string) {
+	b.defers = append(b.defers, nil)
+	b.walkStmts(stmts, typesInfo, self)
+	deferred := b.defers[len(b.defers)-1]
+	b.defers = b.defers[:len(b.defers)-1]
+
+	if len(deferred) > 0 {
+		b.add(seqEvent{Kind: seqNote, From: self, Label: "deferred calls"})
+	}
+	for i := len(deferred) - 1; i >= 0; i-- {
+		d := deferred[i]
+		if lit, ok := d.call.Fun.(*ast.FuncLit); ok {
+			b.walkBody(lit.Body.List, d.typesInfo, self)
+			continue
+		}
+		b.emitCall(d.call, d.typesInfo, self)
+	}
+}
+
+func (b *sequenceBuilder) walkStmts(stmts []ast.Stmt, typesInfo *types.Info, self string) {
+	for _, stmt := range stmts {
+		b.walkStmt(stmt, typesInfo, self)
+	}
+}
+
+// walkStmt は for / range を loop ブロック、if / switch を alt ブロック、go 文を par ブロックとして扱い、
+// defer 文の呼び出しは関数の終了時に、それ以外の文に含まれる呼び出しは出現順に矢印にする
+func (b *sequenceBuilder) walkStmt(stmt ast.Stmt, typesInfo *types.Info, self string) {
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		b.walkStmts(s.List, typesInfo, self)
+	case *ast.LabeledStmt:
+		// outer: for ... のようなラベル付きの文も、ラベルを外して同じように扱う
+		b.walkStmt(s.Stmt, typesInfo, self)
+	case *ast.DeferStmt:
+		// 関数と引数は defer 文の時点で評価され、呼び出しは関数の終了時に行われる
+		b.walkCallOperands(s.Call, typesInfo, self)
+		b.defers[len(b.defers)-1] = append(b.defers[len(b.defers)-1], deferredCall{call: s.Call, typesInfo: typesInfo})
+	case *ast.GoStmt:
+		b.walkCallOperands(s.Call, typesInfo, self)
+		b.walkGo(s.Call, typesInfo, self)
+	case *ast.ForStmt:
+		b.walkStmt(s.Init, typesInfo, self)
+		label := "forever"
+		if s.Cond != nil {
+			label = types.ExprString(s.Cond)
+		}
+		b.add(seqEvent{Kind: seqLoop, Label: label})
+		b.walkExpr(s.Cond, typesInfo, self)
+		b.walkStmts(s.Body.List, typesInfo, self)
+		b.walkStmt(s.Post, typesInfo, self)
+		b.add(seqEvent{Kind: seqEnd})
+	case *ast.RangeStmt:
+		b.walkExpr(s.X, typesInfo, self)
+		b.add(seqEvent{Kind: seqLoop, Label: "range " + types.ExprString(s.X)})
+		b.walkStmts(s.Body.List, typesInfo, self)
+		b.add(seqEvent{Kind: seqEnd})
+	case *ast.IfStmt:
+		b.walkStmt(s.Init, typesInfo, self)
+		b.walkExpr(s.Cond, typesInfo, self)
+		b.add(seqEvent{Kind: seqAlt, Label: types.ExprString(s.Cond)})
+		b.walkStmts(s.Body.List, typesInfo, self)
+		// else if は else ブロックの中の alt として入れ子で表現する
+		if s.Else != nil {
+			b.add(seqEvent{Kind: seqElse})
+			b.walkStmt(s.Else, typesInfo, self)
+		}
+		b.add(seqEvent{Kind: seqEnd})
+	case *ast.SwitchStmt:
+		b.walkStmt(s.Init, typesInfo, self)
+		b.walkExpr(s.Tag, typesInfo, self)
+		b.walkCaseClauses(s.Body.List, s.Tag, typesInfo, self)
+	case *ast.TypeSwitchStmt:
+		b.walkStmt(s.Init, typesInfo, self)
+		b.walkStmt(s.Assign, typesInfo, self)
+		b.walkCaseClauses(s.Body.List, nil, typesInfo, self)
+	case nil:
+	default:
+		b.walkExpr(stmt, typesInfo, self)
+	}
+}
+
+// walkCaseClauses は switch の各 case を alt ブロックの分岐として出力する
+func (b *sequenceBuilder) walkCaseClauses(clauses []ast.Stmt, tag ast.Expr, typesInfo *types.Info, self string) {
+	if len(clauses) == 0 {
+		return
+	}
+	for i, stmt := range clauses {
+		cc, ok := stmt.(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		label := "default"
+		if cc.List != nil {
+			exprs := make([]string, len(cc.List))
+			for j, e := range cc.List {
+				exprs[j] = types.ExprString(e)
+			}
+			label = "case " + strings.Join(exprs, ", ")
+			if tag != nil {
+				label = types.ExprString(tag) + " " + label
+			}
+		}
+		kind := seqElse
+		if i == 0 {
+			kind = seqAlt
+		}
+		b.add(seqEvent{Kind: kind, Label: label})
+		b.walkStmts(cc.Body, typesInfo, self)
+	}
+	b.add(seqEvent{Kind: seqEnd})
+}
+
+// walkExpr は node に含まれる呼び出しを出現順にたどり、解析対象パッケージ内の関数であれば
+// 矢印を出力して呼び出し先の本体へ再帰する (関数リテラルの中身は呼び出し時点では実行されないので除外)
+func (b *sequenceBuilder) walkExpr(node ast.Node, typesInfo *types.Info, self string) {
+	if node == nil {
+		return
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			b.walkCallOperands(n, typesInfo, self)
+			b.emitCall(n, typesInfo, self)
+			return false
+		}
+		return true
+	})
+}
+
+// walkCallOperands は呼び出しの前に評価されるレシーバや引数の中の呼び出しをたどる
+func (b *sequenceBuilder) walkCallOperands(call *ast.CallExpr, typesInfo *types.Info, self string) {
+	b.walkExpr(call.Fun, typesInfo, self)
+	for _, arg := range call.Args {
+		b.walkExpr(arg, typesInfo, self)
+	}
+}
+
+// emitCall は解析対象パッケージ内の関数の呼び出しであれば、矢印を出力して呼び出し先の本体へ再帰する
+func (b *sequenceBuilder) emitCall(call *ast.CallExpr, typesInfo *types.Info, self string) {
+	fn := getFunctionDefinition(call, typesInfo, b.pkgMap)
+	if fn == nil {
+		return
+	}
+	callee := participantName(fn)
+	b.add(seqEvent{Kind: seqCall, From: self, To: callee, Label: fn.Name + typeArgsString(getCallIdent(call), typesInfo)})
+	b.walkFunc(fn, callee)
+	b.add(seqEvent{Kind: seqReturn, From: callee, To: self})
+}
+
+// walkGo は go 文で起動した goroutine を par ブロックとして出力する。
+// 呼び出し元は完了を待たないので、非同期の矢印にして戻りの矢印は出さない。
+func (b *sequenceBuilder) walkGo(call *ast.CallExpr, typesInfo *types.Info, self string) {
+	if lit, ok := call.Fun.(*ast.FuncLit); ok {
+		b.add(seqEvent{Kind: seqPar, Label: "go func literal"})
+		b.walkBody(lit.Body.List, typesInfo, self)
+		b.add(seqEvent{Kind: seqEnd})
+		return
+	}
+	fn := getFunctionDefinition(call, typesInfo, b.pkgMap)
+	if fn == nil {
+		return
+	}
+	callee := participantName(fn)
+	b.add(seqEvent{Kind: seqPar, Label: "go " + fn.Name})
+	b.add(seqEvent{Kind: seqAsync, From: self, To: callee, Label: fn.Name + typeArgsString(getCallIdent(call), typesInfo)})
+	b.walkFunc(fn, callee)
+	b.add(seqEvent{Kind: seqEnd})
+}
+
+// participantName はシーケンス図の参加者名を返す。メソッドならレシーバ型名、関数ならパッケージ名。
+func participantName(fn *FunctionDefinition) string {
+	if fn.Node.Recv != nil && len(fn.Node.Recv.List) > 0 {
+		return recvTypeName(fn.Node.Recv.List[0].Type)
+	}
+	return fn.Package.Name
+}
+
+// participants はイベントに登場する参加者を登場順に返す
+func participants(events []seqEvent) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, ev := range events {
+		for _, name := range []string{ev.From, ev.To} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// renderPlantUML はイベント列を PlantUML のシーケンス図に変換する
+func renderPlantUML(events []seqEvent) string {
+	var sb strings.Builder
+	sb.WriteString("@startuml\n")
+	for _, name := range participants(events) {
+		fmt.Fprintf(&sb, "participant %s\n", name)
+	}
+	depth := 0
+	for _, ev := range events {
+		if ev.Kind == seqEnd || ev.Kind == seqElse {
+			depth--
+		}
+		indent := strings.Repeat("  ", depth)
+		switch ev.Kind {
+		case seqCall:
+			fmt.Fprintf(&sb, "%s%s -> %s : %s\n", indent, ev.From, ev.To, ev.Label)
+		case seqAsync:
+			fmt.Fprintf(&sb, "%s%s ->> %s : %s\n", indent, ev.From, ev.To, ev.Label)
+		case seqReturn:
+			fmt.Fprintf(&sb, "%s%s --> %s\n", indent, ev.From, ev.To)
+		case seqNote:
+			fmt.Fprintf(&sb, "%snote over %s : %s\n", indent, ev.From, ev.Label)
+		case seqLoop, seqAlt, seqElse, seqPar:
+			fmt.Fprintf(&sb, "%s%s\n", indent, strings.TrimSpace(ev.Kind+" "+ev.Label))
+			depth++
+		case seqEnd:
+			fmt.Fprintf(&sb, "%send\n", indent)
+		}
+	}
+	sb.WriteString("@enduml\n")
+	return sb.String()
+}
+
+// renderMermaid はイベント列を Mermaid の sequenceDiagram に変換する
+func renderMermaid(events []seqEvent) string {
+	var sb strings.Builder
+	sb.WriteString("sequenceDiagram\n")
+	for _, name := range participants(events) {
+		fmt.Fprintf(&sb, "  participant %s\n", name)
+	}
+	depth := 1
+	for _, ev := range events {
+		if ev.Kind == seqEnd || ev.Kind == seqElse {
+			depth--
+		}
+		indent := strings.Repeat("  ", depth)
+		switch ev.Kind {
+		case seqCall:
+			fmt.Fprintf(&sb, "%s%s->>%s: %s\n", indent, ev.From, ev.To, ev.Label)
+		case seqAsync:
+			fmt.Fprintf(&sb, "%s%s-)%s: %s\n", indent, ev.From, ev.To, ev.Label)
+		case seqReturn:
+			fmt.Fprintf(&sb, "%s%s-->>%s: return\n", indent, ev.From, ev.To)
+		case seqNote:
+			fmt.Fprintf(&sb, "%sNote over %s: %s\n", indent, ev.From, ev.Label)
+		case seqLoop, seqAlt, seqElse, seqPar:
+			fmt.Fprintf(&sb, "%s%s\n", indent, strings.TrimSpace(ev.Kind+" "+ev.Label))
+			depth++
+		case seqEnd:
+			fmt.Fprintf(&sb, "%send\n", indent)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSequenceExample(t *testing.T) {
+	out := runOutput(t, "sequence", "-rpc", "Culc", "-format", "mermaid")
+	assertContains(t, out,
+		"Client->>ExampleServer: Culc",
+		"ExampleServer->>CulcService: Multiply",
+		"loop i < b",
+		"CulcService->>CulcService: Add",
+		"ExampleServer->>PrintService: Print",
+		"ExampleServer-->>Client: return",
+	)
+}
+
+func TestSequenceGoDeferLabeled(t *testing.T) {
+	dir := fixtureDir(t, "sequence")
+	out := runOutput(t, "sequence", "-dir", dir, "-rpc", "Process")
+
+	assertContains(t, out,
+		// goroutine は非同期の矢印で、戻りの矢印を持たない
+		"par go notify\n  Batch ->> main : notify\nend\n",
+		// ラベル付きの for も loop ブロックになる
+		"loop range req.Items\n  Batch -> main : skip\n",
+	)
+	assertNotContains(t, out, "main --> Batch\nend\n")
+
+	// defer された呼び出しは関数の終了時に、登録と逆の順で出力する
+	deferred := strings.Index(out, "note over Batch : deferred calls")
+	flush := strings.Index(out, "Batch -> main : flush")
+	release := strings.Index(out, "Batch -> main : release")
+	loop := strings.Index(out, "loop range req.Items")
+	if !(loop < deferred && deferred < flush && flush < release) {
+		t.Errorf("deferred calls are not rendered at function exit in LIFO order:\n%s", out)
+	}
+
+	mermaid := runOutput(t, "sequence", "-dir", dir, "-rpc", "Process", "-format", "mermaid")
+	assertContains(t, mermaid, "Batch-)main: notify", "Note over Batch: deferred calls")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+)
+
+// web/ 配下の静的ファイル (シングルページ UI) をバイナリに埋め込む
+//
+//go:embed web
+var webFS embed.FS
+
+// runServe は呼び出しグラフを閲覧する Web UI を起動する
+//
+//	go run . serve -addr localhost:8080 -dir ./example
+func runServe(args []string) error {
+	fset := flag.NewFlagSet("serve", flag.ExitOnError)
+	// 解析対象のソースコードを返すので、既定ではローカルホストからの接続だけを受け付ける
+	addr := fset.String("addr", "localhost:8080", "listen address")
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+	graph := buildCallGraph(pkgs, pkgMap)
+
+	handler, err := newServeHandler(graph)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Call graph UI is running on http://%s", *addr)
+	return http.ListenAndServe(*addr, handler)
+}
+
+// newServeHandler は UI の静的ファイルと、graph を返す API (/api/graph・/api/source) のハンドラを作る
+func newServeHandler(graph *CallGraph) (http.Handler, error) {
+	static, err := fs.Sub(webFS, "web")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.HandleFunc("/api/graph", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, graph)
+	})
+	mux.HandleFunc("/api/source", func(w http.ResponseWriter, r *http.Request) {
+		src, err := graph.source(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, src)
+	})
+	return mux, nil
+}
+
+// SourceSnippet は関数定義のソースコード片
+type SourceSnippet struct {
+	ID     string `json:"id"`
+	Pos    string `json:"pos"`
+	Source string `json:"source"`
+}
+
+// source は関数 ID に対応する FuncDecl のソースコード (doc コメントを含む) を返す
+func (g *CallGraph) source(id string) (*SourceSnippet, error) {
+	def := g.defs[id]
+	if def == nil {
+		return nil, fmt.Errorf("function not found: %s", id)
+	}
+	fset := def.Package.Fset
+	start := def.Node.Pos()
+	if def.Node.Doc != nil {
+		start = def.Node.Doc.Pos()
+	}
+	startPos := fset.Position(start)
+	endPos := fset.Position(def.Node.End())
+
+	content, err := os.ReadFile(startPos.Filename)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceSnippet{
+		ID:     id,
+		Pos:    fset.Position(def.Node.Pos()).String(),
+		Source: string(content[startPos.Offset:endPos.Offset]),
+	}, nil
+}
+
+// writeJSON は v を JSON としてレスポンスに書き出す
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
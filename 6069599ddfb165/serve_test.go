@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServeHandler(t *testing.T) {
+	pkgs, pkgMap, err := loadPackages("./example", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newServeHandler(buildCallGraph(pkgs, pkgMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	if rec := get("/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<html") {
+		t.Errorf("GET / = %d, body does not look like the embedded UI", rec.Code)
+	}
+
+	rec := get("/api/graph")
+	var graph struct {
+		RPCs      []*RPCEntry     `json:"rpcs"`
+		Functions []*FunctionInfo `json:"functions"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&graph); err != nil {
+		t.Fatalf("decoding /api/graph: %v", err)
+	}
+	if len(graph.RPCs) != 1 || graph.RPCs[0].Name != "ExampleServer.Culc" {
+		t.Fatalf("rpcs = %+v", graph.RPCs)
+	}
+
+	rec = get("/api/source?id=" + url.QueryEscape(graph.RPCs[0].FuncID))
+	var src SourceSnippet
+	if err := json.NewDecoder(rec.Body).Decode(&src); err != nil {
+		t.Fatalf("decoding /api/source: %v", err)
+	}
+	if !strings.Contains(src.Source, "func (s *ExampleServer) Culc(") {
+		t.Errorf("source of Culc = %q", src.Source)
+	}
+
+	if rec := get("/api/source?id=no.such.Func"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown id: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,3 @@
+module fixture/concurrency
+
+go 1.23
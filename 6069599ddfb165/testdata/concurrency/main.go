@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+type counter struct {
+	mu    sync.Mutex
+	hits  map[string]int
+	last  string
+	total atomic.Int64
+}
+
+var stats = &counter{hits: make(map[string]int)}
+
+// inc は defer した Unlock まで保持したロックの中でフィールドを書き換える
+func (c *counter) inc(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hits[path]++
+}
+
+// reset は Unlock した後にもフィールドを書き換える
+func (c *counter) reset() {
+	c.mu.Lock()
+	c.hits = make(map[string]int)
+	c.mu.Unlock()
+	c.last = ""
+}
+
+// statsHandler は goroutine とチャネルで集計する
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	results := make(chan int)
+	go sum(results)
+	go func() {
+		results <- 1
+		close(results)
+	}()
+	stats.inc(r.URL.Path)
+	stats.total.Add(1)
+	fmt.Fprintln(w, <-results)
+}
+
+func sum(results chan int) {
+	for range results {
+	}
+}
+
+func resetHandler(w http.ResponseWriter, r *http.Request) {
+	stats.reset()
+}
+
+func main() {
+	http.HandleFunc("/stats", statsHandler)
+	http.HandleFunc("/reset", resetHandler)
+	http.ListenAndServe(":8080", nil)
+}
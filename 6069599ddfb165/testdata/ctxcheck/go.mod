@@ -0,0 +1,3 @@
+module fixture/ctxcheck
+
+go 1.23
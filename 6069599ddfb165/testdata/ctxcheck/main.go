@@ -0,0 +1,53 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+)
+
+var db *sql.DB
+
+// usersHandler は context を受け取らない関数を経由して DB にアクセスする
+func usersHandler(w http.ResponseWriter, r *http.Request) {
+	names := loadUsers()
+	fmt.Fprintln(w, names)
+}
+
+func loadUsers() []string {
+	return queryUsers()
+}
+
+func queryUsers() []string {
+	rows, err := db.Query("SELECT name FROM users")
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	return nil
+}
+
+// fetchHandler は context を受け取っているが、context を受け取らない http.Get を使う
+func fetchHandler(w http.ResponseWriter, r *http.Request) {
+	resp, err := http.Get("https://example.com")
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// countHandler は context を渡して DB にアクセスし、I/O を行わない関数だけを context なしで呼ぶ
+func countHandler(w http.ResponseWriter, r *http.Request) {
+	var n int
+	db.QueryRowContext(r.Context(), "SELECT count(*) FROM users").Scan(&n)
+	fmt.Fprintln(w, label(n))
+}
+
+func label(n int) string { return fmt.Sprintf("%d users", n) }
+
+func main() {
+	http.HandleFunc("/users", usersHandler)
+	http.HandleFunc("/fetch", fetchHandler)
+	http.HandleFunc("/count", countHandler)
+	http.ListenAndServe(":8080", nil)
+}
@@ -0,0 +1,12 @@
+module fixture/errors
+
+go 1.23.4
+
+require google.golang.org/grpc v1.69.4
+
+require (
+	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250106144421-5f5ef82da422 // indirect
+	google.golang.org/protobuf v1.36.3 // indirect
+)
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"fixture/errors/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Files struct{}
+
+// Open は受け取ったエラーを同じ変数に再代入してラップする
+func (f *Files) Open(req *pb.Request) (*pb.Response, error) {
+	err := load(req.Name)
+	if err != nil {
+		err = fmt.Errorf("open: %w", err)
+		return nil, err
+	}
+	return &pb.Response{}, nil
+}
+
+// Stat は status.Convert でステータス付きのエラーに変換する
+func (f *Files) Stat(req *pb.Request) (*pb.Response, error) {
+	if err := read(req.Name); err != nil {
+		return nil, status.Convert(err).Err()
+	}
+	return &pb.Response{}, nil
+}
+
+// Index は明示的な引数インデックスでラップする
+func (f *Files) Index(req *pb.Request) (*pb.Response, error) {
+	if err := load(req.Name); err != nil {
+		return nil, fmt.Errorf("index %[2]q: %[1]w", err, req.Name)
+	}
+	return &pb.Response{}, nil
+}
+
+func load(name string) error {
+	if name == "" {
+		return status.Error(codes.InvalidArgument, "empty name")
+	}
+	return nil
+}
+
+func read(name string) error {
+	return errors.New("read failed")
+}
+
+func main() {
+	pb.RegisterFileServer(&pb.Registrar{}, &Files{})
+}
@@ -0,0 +1,16 @@
+// Package pb は protoc-gen-go-grpc の生成コードに似せたテスト用のパッケージ
+package pb
+
+type Request struct{ Name string }
+
+type Response struct{}
+
+type Registrar struct{}
+
+type FileServer interface {
+	Open(*Request) (*Response, error)
+	Stat(*Request) (*Response, error)
+	Index(*Request) (*Response, error)
+}
+
+func RegisterFileServer(s *Registrar, srv FileServer) {}
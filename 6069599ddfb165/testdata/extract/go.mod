@@ -0,0 +1,3 @@
+module fixture/extract
+
+go 1.23
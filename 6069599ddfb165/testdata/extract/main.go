@@ -0,0 +1,61 @@
+package main
+
+import "net/http"
+
+func main() {
+	h := &Handler{store: &Store{dir: "/tmp"}}
+	http.HandleFunc("/get", h.get)
+	http.HandleFunc("/put", h.put)
+	http.HandleFunc("/dump", dumpHandler)
+	http.HandleFunc("/health", healthHandler)
+	http.ListenAndServe("localhost:8080", nil)
+}
+
+// Store はキーと値を保存する
+type Store struct {
+	dir string
+}
+
+// Get は key の値を返す
+func (s *Store) Get(key string) string { return s.load(key) }
+
+// Put は key に value を保存する
+func (s *Store) Put(key, value string) {}
+
+// Delete は key を削除する
+func (s *Store) Delete(key string) {}
+
+func (s *Store) load(key string) string { return s.dir + "/" + key }
+
+// Handler は Store を使う HTTP ハンドラ
+type Handler struct {
+	store *Store
+	name  string
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(h.store.Get(r.URL.Query().Get("key"))))
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	h.store.Put(r.URL.Query().Get("key"), r.URL.Query().Get("value"))
+	w.Write([]byte(h.store.Get(r.URL.Query().Get("key"))))
+}
+
+// purge はハンドラから呼ばれないが、Delete はインターフェイスに含まれない
+func (h *Handler) purge() { h.store.Delete("all") }
+
+func (h *Handler) dir() string { return h.store.dir }
+
+func (h *Handler) backup() { backupStore(h.store) }
+
+func backupStore(s *Store) {}
+
+func dumpHandler(w http.ResponseWriter, r *http.Request) {
+	s := &Store{dir: "/tmp"}
+	w.Write([]byte(s.Get("all")))
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,3 @@
+module fixture/generics
+
+go 1.23
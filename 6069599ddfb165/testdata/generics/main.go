@@ -0,0 +1,23 @@
+package main
+
+import "strconv"
+
+type Stack[T any] struct{ items []T }
+
+func (s *Stack[T]) Push(v T) { s.items = append(s.items, v) }
+
+func Map[T, U any](in []T, f func(T) U) []U {
+	out := make([]U, 0, len(in))
+	for _, v := range in {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func format(n int) string { return strconv.Itoa(n) }
+
+func main() {
+	s := &Stack[int]{}
+	s.Push(1)
+	_ = Map([]int{1, 2}, format)
+}
@@ -0,0 +1,3 @@
+module fixture/httptests
+
+go 1.23
@@ -0,0 +1,27 @@
+package main
+
+import "net/http"
+
+func main() {
+	http.HandleFunc("/hello", helloHandler)
+	http.Handle("/admin", requireAdmin(http.HandlerFunc(adminHandler)))
+	http.ListenAndServe("localhost:8080", nil)
+}
+
+func helloHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(greeting()))
+}
+
+func greeting() string { return "hello" }
+
+func adminHandler(w http.ResponseWriter, r *http.Request) {}
+
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Admin") == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
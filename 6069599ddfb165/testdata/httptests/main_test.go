@@ -0,0 +1,16 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHello(t *testing.T) {
+	helloHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+}
+
+func TestGreeting(t *testing.T) {
+	if greeting() == "" {
+		t.Fatal("empty greeting")
+	}
+}
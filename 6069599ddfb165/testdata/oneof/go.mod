@@ -0,0 +1,3 @@
+module fixture/oneof
+
+go 1.23
@@ -0,0 +1,24 @@
+package main
+
+import "fixture/oneof/searchpb"
+
+type server struct{}
+
+// Search は oneof のメンバーを型 switch・getter で読み、ラッパー型で設定する
+func (s *server) Search(req *searchpb.SearchRequest) (*searchpb.SearchResponse, error) {
+	switch q := req.Query.(type) {
+	case *searchpb.SearchRequest_Tag:
+		return &searchpb.SearchResponse{Result: &searchpb.SearchResponse_Hit{Hit: q.Tag}}, nil
+	}
+	text := req.GetText()
+	if text == "" {
+		resp := &searchpb.SearchResponse{}
+		resp.Result = &searchpb.SearchResponse_ErrorMessage{ErrorMessage: "empty query"}
+		return resp, nil
+	}
+	return &searchpb.SearchResponse{Result: &searchpb.SearchResponse_Hit{Hit: text}}, nil
+}
+
+func main() {
+	searchpb.RegisterSearchServiceServer(&searchpb.Registrar{}, &server{})
+}
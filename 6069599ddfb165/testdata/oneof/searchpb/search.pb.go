@@ -0,0 +1,108 @@
+// Package searchpb は proto/search.proto から protoc-gen-go / protoc-gen-go-grpc が生成するコードに似せたテスト用のパッケージ
+package searchpb
+
+type SearchRequest struct {
+	// Types that are assignable to Query:
+	//
+	//	*SearchRequest_Text
+	//	*SearchRequest_Tag
+	//	*SearchRequest_UserId
+	Query isSearchRequest_Query `protobuf_oneof:"query"`
+	Limit int32                 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *SearchRequest) GetQuery() isSearchRequest_Query {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetText() string {
+	if x, ok := x.GetQuery().(*SearchRequest_Text); ok {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetTag() string {
+	if x, ok := x.GetQuery().(*SearchRequest_Tag); ok {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetUserId() int64 {
+	if x, ok := x.GetQuery().(*SearchRequest_UserId); ok {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type isSearchRequest_Query interface {
+	isSearchRequest_Query()
+}
+
+type SearchRequest_Text struct {
+	Text string `protobuf:"bytes,1,opt,name=text,proto3,oneof"`
+}
+
+type SearchRequest_Tag struct {
+	Tag string `protobuf:"bytes,2,opt,name=tag,proto3,oneof"`
+}
+
+type SearchRequest_UserId struct {
+	UserId int64 `protobuf:"varint,3,opt,name=user_id,json=userId,proto3,oneof"`
+}
+
+func (*SearchRequest_Text) isSearchRequest_Query() {}
+
+func (*SearchRequest_Tag) isSearchRequest_Query() {}
+
+func (*SearchRequest_UserId) isSearchRequest_Query() {}
+
+type SearchResponse struct {
+	// Types that are assignable to Result:
+	//
+	//	*SearchResponse_Hit
+	//	*SearchResponse_ErrorMessage
+	//	*SearchResponse_Empty
+	Result isSearchResponse_Result `protobuf_oneof:"result"`
+}
+
+type isSearchResponse_Result interface {
+	isSearchResponse_Result()
+}
+
+type SearchResponse_Hit struct {
+	Hit string `protobuf:"bytes,1,opt,name=hit,proto3,oneof"`
+}
+
+type SearchResponse_ErrorMessage struct {
+	ErrorMessage string `protobuf:"bytes,2,opt,name=error_message,json=errorMessage,proto3,oneof"`
+}
+
+type SearchResponse_Empty struct {
+	Empty bool `protobuf:"varint,3,opt,name=empty,proto3,oneof"`
+}
+
+func (*SearchResponse_Hit) isSearchResponse_Result() {}
+
+func (*SearchResponse_ErrorMessage) isSearchResponse_Result() {}
+
+func (*SearchResponse_Empty) isSearchResponse_Result() {}
+
+type Registrar struct{}
+
+type SearchServiceServer interface {
+	Search(*SearchRequest) (*SearchResponse, error)
+}
+
+func RegisterSearchServiceServer(s *Registrar, srv SearchServiceServer) {}
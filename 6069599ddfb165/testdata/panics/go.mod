@@ -0,0 +1,3 @@
+module fixture/panics
+
+go 1.23
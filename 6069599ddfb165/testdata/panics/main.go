@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+)
+
+func main() {
+	http.HandleFunc("/parse", parseHandler)
+	http.HandleFunc("/exit", exitHandler)
+	http.Handle("/safe", recoverMiddleware(http.HandlerFunc(safeHandler)))
+	http.HandleFunc("/ok", okHandler)
+	http.ListenAndServe("localhost:8080", nil)
+}
+
+func parseHandler(w http.ResponseWriter, r *http.Request) {
+	pattern := mustPattern(r.URL.Query().Get("q"))
+	w.Write([]byte(pattern.String()))
+}
+
+func mustPattern(q string) *regexp.Regexp {
+	var counts map[string]int
+	counts[q]++
+	return regexp.MustCompile(q)
+}
+
+func exitHandler(w http.ResponseWriter, r *http.Request) {
+	log.Fatal("shutting down")
+}
+
+func safeHandler(w http.ResponseWriter, r *http.Request) {
+	var v any = r.Context().Value("user")
+	name := v.(string)
+	w.Write([]byte(name))
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	var v any = r.Context().Value("user")
+	if name, ok := v.(string); ok {
+		w.Write([]byte(name))
+	}
+}
+
+// recoverMiddleware は包んだハンドラの panic を recover する
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"strings"
+
+	"fixture/pkggraph/lib"
+)
+
+// Run は lib の関数・メソッドを 3 回呼び出す。型変換・メソッド値・関数型のフィールドの呼び出しは数えない。
+func Run() string {
+	c := lib.Parse("1")
+	c += lib.Celsius(2)
+	t := &lib.Thermo{Hook: func() {}}
+	read := t.Read
+	read()
+	t.Hook()
+	c += (lib.Parse)("3")
+	return strings.ToUpper(c.String())
+}
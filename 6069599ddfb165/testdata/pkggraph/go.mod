@@ -0,0 +1,3 @@
+module fixture/pkggraph
+
+go 1.23
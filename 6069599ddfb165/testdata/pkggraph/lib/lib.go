@@ -0,0 +1,18 @@
+package lib
+
+import "strconv"
+
+type Celsius float64
+
+func Parse(s string) Celsius {
+	f, _ := strconv.ParseFloat(s, 64)
+	return Celsius(f)
+}
+
+func (c Celsius) String() string { return strconv.FormatFloat(float64(c), 'f', 1, 64) + "C" }
+
+type Thermo struct {
+	Hook func()
+}
+
+func (t *Thermo) Read() Celsius { return 20 }
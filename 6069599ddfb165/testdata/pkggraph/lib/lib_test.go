@@ -0,0 +1,14 @@
+package lib
+
+import (
+	"testing"
+
+	"fixture/pkggraph/app"
+)
+
+// app が lib を import しているので、このテストは import cycle not allowed in test になる
+func TestRun(t *testing.T) {
+	if app.Run() == "" {
+		t.Fatal("empty")
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"fmt"
+
+	"fixture/pkggraph/app"
+)
+
+func main() {
+	fmt.Println(app.Run())
+}
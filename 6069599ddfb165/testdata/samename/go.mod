@@ -0,0 +1,3 @@
+module fixture/samename
+
+go 1.23
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"fixture/samename/pb"
+	"fixture/samename/server"
+)
+
+func main() {
+	pb.RegisterStoreServer(&pb.Registrar{}, &server.Store{})
+}
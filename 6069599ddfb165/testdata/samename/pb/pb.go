@@ -0,0 +1,14 @@
+// Package pb は protoc-gen-go-grpc の生成コードに似せたテスト用のパッケージ
+package pb
+
+type Request struct{ Key string }
+
+type Response struct{ Value string }
+
+type Registrar struct{}
+
+type StoreServer interface {
+	Get(*Request) (*Response, error)
+}
+
+func RegisterStoreServer(s *Registrar, srv StoreServer) {}
@@ -0,0 +1,12 @@
+package server
+
+import "fixture/samename/pb"
+
+// Cache は Store と同じ名前のメソッド Get を持つが、gRPC には登録されていない
+type Cache struct{}
+
+func (c *Cache) Get(req *pb.Request) (*pb.Response, error) {
+	return cacheOnly(), nil
+}
+
+func cacheOnly() *pb.Response { return &pb.Response{} }
@@ -0,0 +1,11 @@
+package server
+
+import "fixture/samename/pb"
+
+type Store struct{}
+
+func (s *Store) Get(req *pb.Request) (*pb.Response, error) {
+	return lookup(req.Key), nil
+}
+
+func lookup(key string) *pb.Response { return &pb.Response{Value: key} }
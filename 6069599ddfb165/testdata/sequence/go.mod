@@ -0,0 +1,3 @@
+module fixture/sequence
+
+go 1.23
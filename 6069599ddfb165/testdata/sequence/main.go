@@ -0,0 +1,36 @@
+package main
+
+import "fixture/sequence/pb"
+
+type Batch struct{}
+
+// Process はラベル付きのループ、goroutine、defer を含む RPC の実装
+func (b *Batch) Process(req *pb.Request) (*pb.Response, error) {
+	defer release()
+	defer func() {
+		flush()
+	}()
+	go notify(len(req.Items))
+
+	count := 0
+outer:
+	for _, item := range req.Items {
+		if skip(item) {
+			continue outer
+		}
+		count++
+	}
+	return &pb.Response{Count: count}, nil
+}
+
+func skip(item string) bool { return item == "" }
+
+func notify(n int) {}
+
+func flush() {}
+
+func release() {}
+
+func main() {
+	pb.RegisterBatchServer(&pb.Registrar{}, &Batch{})
+}
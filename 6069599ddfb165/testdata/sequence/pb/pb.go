@@ -0,0 +1,14 @@
+// Package pb は protoc-gen-go-grpc の生成コードに似せたテスト用のパッケージ
+package pb
+
+type Request struct{ Items []string }
+
+type Response struct{ Count int }
+
+type Registrar struct{}
+
+type BatchServer interface {
+	Process(*Request) (*Response, error)
+}
+
+func RegisterBatchServer(s *Registrar, srv BatchServer) {}
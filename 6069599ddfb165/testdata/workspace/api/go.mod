@@ -0,0 +1,3 @@
+module fixture/api
+
+go 1.23
@@ -0,0 +1,49 @@
+// Package hellopb は protoc-gen-go-grpc が hello.proto (package hello; service Greeter) から生成するコードに似せたテスト用のパッケージ
+package hellopb
+
+type Request struct{ Name string }
+
+type Response struct{ Message string }
+
+const (
+	Greeter_SayHello_FullMethodName = "/hello.Greeter/SayHello"
+	Greeter_SayBye_FullMethodName   = "/hello.Greeter/SayBye"
+)
+
+type Conn struct{}
+
+func (c *Conn) Invoke(method string, in, out any) error { return nil }
+
+type GreeterClient interface {
+	SayHello(in *Request) (*Response, error)
+	SayBye(in *Request) (*Response, error)
+}
+
+type greeterClient struct {
+	cc *Conn
+}
+
+func NewGreeterClient(cc *Conn) GreeterClient {
+	return &greeterClient{cc}
+}
+
+func (c *greeterClient) SayHello(in *Request) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(Greeter_SayHello_FullMethodName, in, out)
+	return out, err
+}
+
+func (c *greeterClient) SayBye(in *Request) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(Greeter_SayBye_FullMethodName, in, out)
+	return out, err
+}
+
+type Registrar struct{}
+
+type GreeterServer interface {
+	SayHello(*Request) (*Response, error)
+	SayBye(*Request) (*Response, error)
+}
+
+func RegisterGreeterServer(s *Registrar, srv GreeterServer) {}
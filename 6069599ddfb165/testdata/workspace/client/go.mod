@@ -0,0 +1,3 @@
+module fixture/client
+
+go 1.23
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"fmt"
+
+	"fixture/api/hellopb"
+)
+
+func main() {
+	client := hellopb.NewGreeterClient(&hellopb.Conn{})
+	hello, _ := client.SayHello(&hellopb.Request{Name: "gopher"})
+	bye, _ := client.SayBye(&hellopb.Request{Name: "gopher"})
+	fmt.Println(hello.Message, bye.Message)
+}
@@ -0,0 +1,3 @@
+module fixture/legacy
+
+go 1.23
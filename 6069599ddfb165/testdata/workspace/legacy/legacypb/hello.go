@@ -0,0 +1,28 @@
+// Package legacypb は同じ hello.proto から FullMethodName 定数のない古い protoc-gen-go-grpc で別途生成したコードに似せたテスト用のパッケージ
+package legacypb
+
+type Request struct{ Name string }
+
+type Response struct{ Message string }
+
+type UnaryServerInfo struct {
+	Server     any
+	FullMethod string
+}
+
+type Registrar struct{}
+
+type GreeterServer interface {
+	SayBye(*Request) (*Response, error)
+}
+
+func _Greeter_SayBye_Handler(srv any, in *Request) (*Response, error) {
+	info := &UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hello.Greeter/SayBye",
+	}
+	_ = info
+	return srv.(GreeterServer).SayBye(in)
+}
+
+func RegisterGreeterServer(s *Registrar, srv GreeterServer) {}
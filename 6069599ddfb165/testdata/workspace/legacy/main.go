@@ -0,0 +1,13 @@
+package main
+
+import "fixture/legacy/legacypb"
+
+type legacyGreeter struct{}
+
+func (g *legacyGreeter) SayBye(req *legacypb.Request) (*legacypb.Response, error) {
+	return &legacypb.Response{Message: "good bye"}, nil
+}
+
+func main() {
+	legacypb.RegisterGreeterServer(&legacypb.Registrar{}, &legacyGreeter{})
+}
@@ -0,0 +1,3 @@
+module fixture/other
+
+go 1.23
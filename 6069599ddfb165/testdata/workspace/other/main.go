@@ -0,0 +1,14 @@
+package main
+
+import "fixture/other/otherpb"
+
+// otherGreeter はサービス名とメソッド名は同じだが、別の proto パッケージの RPC を実装する
+type otherGreeter struct{}
+
+func (g *otherGreeter) SayHello(req *otherpb.Request) (*otherpb.Response, error) {
+	return &otherpb.Response{}, nil
+}
+
+func main() {
+	otherpb.RegisterGreeterServer(&otherpb.Registrar{}, &otherGreeter{})
+}
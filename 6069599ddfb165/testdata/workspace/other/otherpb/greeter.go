@@ -0,0 +1,16 @@
+// Package otherpb は別の proto パッケージ (package other; service Greeter) の生成コードに似せたテスト用のパッケージ
+package otherpb
+
+type Request struct{ Name string }
+
+type Response struct{ Message string }
+
+const Greeter_SayHello_FullMethodName = "/other.Greeter/SayHello"
+
+type Registrar struct{}
+
+type GreeterServer interface {
+	SayHello(*Request) (*Response, error)
+}
+
+func RegisterGreeterServer(s *Registrar, srv GreeterServer) {}
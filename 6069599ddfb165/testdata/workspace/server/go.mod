@@ -0,0 +1,3 @@
+module fixture/server
+
+go 1.23
@@ -0,0 +1,19 @@
+package main
+
+import "fixture/api/hellopb"
+
+type greeter struct{}
+
+func (g *greeter) SayHello(req *hellopb.Request) (*hellopb.Response, error) {
+	return &hellopb.Response{Message: greet(req.Name)}, nil
+}
+
+func (g *greeter) SayBye(req *hellopb.Request) (*hellopb.Response, error) {
+	return &hellopb.Response{Message: "bye"}, nil
+}
+
+func greet(name string) string { return "hello " + name }
+
+func main() {
+	hellopb.RegisterGreeterServer(&hellopb.Registrar{}, &greeter{})
+}
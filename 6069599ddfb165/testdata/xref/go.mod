@@ -0,0 +1,3 @@
+module fixture/xref
+
+go 1.23
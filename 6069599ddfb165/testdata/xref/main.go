@@ -0,0 +1,31 @@
+package main
+
+import "fixture/xref/storepb"
+
+// server は DeleteItem を実装しておらず、UnimplementedStoreServiceServer のものを使う
+type server struct {
+	storepb.UnimplementedStoreServiceServer
+}
+
+func (s *server) GetItem(req *storepb.GetItemRequest) (*storepb.GetItemResponse, error) {
+	item := lookup(req.GetId())
+	return &storepb.GetItemResponse{Item: item}, nil
+}
+
+func (s *server) ListItems(req *storepb.ListItemsRequest) (*storepb.ListItemsResponse, error) {
+	resp := &storepb.ListItemsResponse{}
+	for i := int32(0); i < req.PageSize; i++ {
+		resp.Items = append(resp.Items, lookup("item"))
+	}
+	resp.NextPageToken = "next"
+	return resp, nil
+}
+
+// lookup は RPC 実装から呼ばれ、Item のフィールドを設定する
+func lookup(id string) *storepb.Item {
+	return &storepb.Item{Name: id}
+}
+
+func main() {
+	storepb.RegisterStoreServiceServer(&storepb.Registrar{}, &server{})
+}
@@ -0,0 +1,65 @@
+// Package storepb は proto/store.proto から protoc-gen-go / protoc-gen-go-grpc が生成するコードに似せたテスト用のパッケージ
+package storepb
+
+type Item struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Price int64  `protobuf:"varint,2,opt,name=price,proto3" json:"price,omitempty"`
+}
+
+type GetItemRequest struct {
+	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Locale string `protobuf:"bytes,2,opt,name=locale,proto3" json:"locale,omitempty"`
+}
+
+func (x *GetItemRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetItemResponse struct {
+	Item *Item  `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	Etag string `protobuf:"bytes,2,opt,name=etag,proto3" json:"etag,omitempty"`
+}
+
+type ListItemsRequest struct {
+	PageSize  int32  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+}
+
+type ListItemsResponse struct {
+	Items         []*Item `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	NextPageToken string  `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+}
+
+type DeleteItemRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+type DeleteItemResponse struct{}
+
+type Registrar struct{}
+
+type StoreServiceServer interface {
+	GetItem(*GetItemRequest) (*GetItemResponse, error)
+	ListItems(*ListItemsRequest) (*ListItemsResponse, error)
+	DeleteItem(*DeleteItemRequest) (*DeleteItemResponse, error)
+}
+
+// UnimplementedStoreServiceServer は未実装の RPC に対してエラーを返す
+type UnimplementedStoreServiceServer struct{}
+
+func (UnimplementedStoreServiceServer) GetItem(*GetItemRequest) (*GetItemResponse, error) {
+	return nil, nil
+}
+
+func (UnimplementedStoreServiceServer) ListItems(*ListItemsRequest) (*ListItemsResponse, error) {
+	return nil, nil
+}
+
+func (UnimplementedStoreServiceServer) DeleteItem(*DeleteItemRequest) (*DeleteItemResponse, error) {
+	return nil, nil
+}
+
+func RegisterStoreServiceServer(s *Registrar, srv StoreServiceServer) {}
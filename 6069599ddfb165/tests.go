@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// runTests は _test.go を含めて読み込み、各 TestXxx から静的に到達できる
+// gRPC RPC / HTTP ハンドラを調べて「どのテストがどのエントリーポイントを通るか」を出力する
+//
+//	go run . tests -dir ./example
+func runTests(args []string) error {
+	fset := flag.NewFlagSet("tests", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, true)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	var tests, targets []*EntryPoint
+	for _, ep := range collectEntryPoints(pkgs, pkgMap) {
+		switch ep.Kind {
+		case EntryTest:
+			tests = append(tests, ep)
+		case EntryGRPC, EntryHTTP:
+			targets = append(targets, ep)
+		}
+	}
+
+	// テストごとに到達可能な関数を求めておく
+	reached := make(map[*EntryPoint]map[string]*FunctionDefinition)
+	for _, test := range tests {
+		reached[test] = reachableFuncs(test.Def, pkgMap)
+	}
+
+	fmt.Println("=== Tests reaching each RPC / HTTP handler ===")
+	var untested []*EntryPoint
+	for _, target := range targets {
+		fmt.Printf("[%s] %s\n", target.Kind, target.Name)
+		id := functionID(target.Def)
+		count := 0
+		for _, test := range tests {
+			if reached[test][id] == nil {
+				continue
+			}
+			fmt.Printf("  %s (%s)\n", test.Name, test.Def.Package.Fset.Position(test.Def.Node.Pos()))
+			count++
+		}
+		if count == 0 {
+			fmt.Println("  (no tests)")
+			untested = append(untested, target)
+		}
+	}
+
+	fmt.Println("\n=== RPC / HTTP handlers not reached by any test ===")
+	for _, target := range untested {
+		fmt.Printf("[%s] %s (%s)\n", target.Kind, target.Name, target.Def.Package.Fset.Position(target.Def.Node.Pos()))
+	}
+	fmt.Printf("%d tests, %d/%d entry points covered\n", len(tests), len(targets)-len(untested), len(targets))
+	return nil
+}
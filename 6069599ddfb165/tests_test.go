@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestTestsExample(t *testing.T) {
+	out := runOutput(t, "tests", "-dir", "./example")
+	assertContains(t, out,
+		"[grpc] ExampleServer.Culc\n  (no tests)",
+		"0 tests, 0/1 entry points covered",
+	)
+}
+
+func TestTestsFixture(t *testing.T) {
+	out := runOutput(t, "tests", "-dir", fixtureDir(t, "httptests"))
+	assertContains(t, out,
+		"[http] /hello helloHandler\n  TestHello (",
+		"[http] /admin adminHandler\n  (no tests)",
+		"2 tests, 1/2 entry points covered",
+	)
+	// TestGreeting は helloHandler を通らない
+	assertNotContains(t, out, "  TestGreeting (")
+}
+
+func TestHTTPHandlerMiddleware(t *testing.T) {
+	pkgs, pkgMap := loadFixture(t, "httptests")
+	ep := findEntry(t, collectEntryPoints(pkgs, pkgMap), "/admin adminHandler")
+	if len(ep.Middleware) != 1 || ep.Middleware[0].Name != "requireAdmin" {
+		t.Fatalf("middleware of /admin = %v", ep.Middleware)
+	}
+	if _, ok := entryReachableFuncs(ep, pkgMap)["fixture/httptests.requireAdmin"]; !ok {
+		t.Error("requireAdmin is not analyzed as part of /admin")
+	}
+}
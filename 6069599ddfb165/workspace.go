@@ -0,0 +1,293 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// isWorkFile は -dir に go.work ファイルが指定されたかどうかを判定する
+func isWorkFile(path string) bool {
+	return filepath.Base(path) == "go.work"
+}
+
+// workspaceModules は go.work の use ディレクティブに書かれたモジュールのディレクトリを絶対パスで返す
+func workspaceModules(workFile string) ([]string, error) {
+	data, err := os.ReadFile(workFile)
+	if err != nil {
+		return nil, err
+	}
+	work, err := modfile.ParseWork(workFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(filepath.Dir(workFile))
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, use := range work.Use {
+		dir := use.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// runWorkspace は go.work の全モジュールをまとめて読み込み、gRPC クライアントの呼び出しを
+// 別モジュールでサービス登録されている実装メソッドへつないだ呼び出しツリーを出力する
+//
+//	go run . workspace -work ../go.work
+func runWorkspace(args []string) error {
+	fset := flag.NewFlagSet("workspace", flag.ExitOnError)
+	work := fset.String("work", "../go.work", "go.work file of the workspace")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*work, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+	eps := collectEntryPoints(pkgs, pkgMap)
+	links := findGRPCClientCalls(pkgs, eps)
+
+	fmt.Println("=== Workspace modules ===")
+	modules := make(map[string][]*packages.Package)
+	for _, pkg := range pkgs {
+		modules[packageModule(pkg)] = append(modules[packageModule(pkg)], pkg)
+	}
+	var names []string
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var errs int
+		for _, pkg := range modules[name] {
+			errs += len(pkg.Errors)
+		}
+		fmt.Printf("%s: %d packages", name, len(modules[name]))
+		if errs > 0 {
+			fmt.Printf(" (%d load errors)", errs)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("\n=== gRPC client calls ===")
+	if len(links) == 0 {
+		fmt.Println("(none)")
+	}
+	for _, link := range links {
+		fmt.Printf("%s: %s calls %s\n", link.Pos, funcDeclName(link.Caller.Node), link.Method)
+		switch len(link.Candidates) {
+		case 0:
+			fmt.Println("  => (no implementation registered in the workspace)")
+		case 1:
+			fmt.Printf("  => [%s] %s (%s)\n", link.Impl.Kind, link.Impl.Name, packageModule(link.Impl.Def.Package))
+		default:
+			fmt.Printf("  => ambiguous: %d implementations are registered for %s\n", len(link.Candidates), link.Method)
+			for _, ep := range link.Candidates {
+				fmt.Printf("     [%s] %s (%s)\n", ep.Kind, ep.Name, packageModule(ep.Def.Package))
+			}
+		}
+	}
+
+	// クライアント呼び出しを含むエントリーポイントから、サーバ側の実装までつないだツリーを出力する
+	fmt.Println("\n=== Cross-module call trees ===")
+	byPos := make(map[string]*grpcClientLink)
+	for _, link := range links {
+		byPos[link.Pos] = link
+	}
+	for _, ep := range eps {
+		if ep.Kind == EntryGRPC || ep.Kind == EntryTest {
+			continue
+		}
+		reached := entryReachableFuncs(ep, pkgMap)
+		callsClient := false
+		for _, link := range links {
+			// 壊れたパッケージで同名の関数が重複している場合に取り違えないよう、宣言ノードで照合する
+			if def := reached[functionID(link.Caller)]; def != nil && def.Node == link.Caller.Node {
+				callsClient = true
+				break
+			}
+		}
+		if !callsClient {
+			continue
+		}
+		fmt.Printf("[%s] %s (%s)\n", ep.Kind, ep.Name, packageModule(ep.Def.Package))
+		tree := buildCallTree(ep.Def.Node.Body, ep.Def.Package, pkgMap, make(map[string]bool))
+		printLinkedTree(tree, 1, byPos, pkgMap, make(map[string]bool))
+	}
+	return nil
+}
+
+// grpcClientLink は生成された <Service>Client インターフェイスのメソッド呼び出しと、
+// 同じ RPC として登録されている実装メソッドとの対応
+type grpcClientLink struct {
+	Caller     *FunctionDefinition // クライアントのメソッドを呼んでいる関数
+	Pos        string              // 呼び出し位置 (CallNode.Pos と同じ形式)
+	Method     string              // /package.Service/Method (生成コードから求められなければ Service/Method)
+	Candidates []*EntryPoint       // 同じ RPC として登録されている実装メソッド
+	Impl       *EntryPoint         // 実装メソッドが 1 つに決まればそれ (見つからない・曖昧なら nil)
+}
+
+// findGRPCClientCalls は client.Culc(ctx, req) のような生成クライアントの呼び出しを探し、
+// 同じ RPC として登録されている EntryGRPC に対応付ける。
+// RPC は生成コードの完全なメソッド名 (/example.ExampleService/Culc) で照合するので、クライアントとサーバが
+// 別モジュールで別々に生成したコードを使っていても対応付けられ、proto のパッケージが違う同名のサービスとは区別できる。
+// 完全なメソッド名が求められない場合は、クライアントと同じ生成パッケージの Register<Service>Server で登録された実装だけを対応付ける。
+func findGRPCClientCalls(pkgs []*packages.Package, eps []*EntryPoint) []*grpcClientLink {
+	allPkgs := make(map[string]*packages.Package)
+	packages.Visit(pkgs, nil, func(p *packages.Package) { allPkgs[p.PkgPath] = p })
+
+	var links []*grpcClientLink
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				caller := &FunctionDefinition{Pkg: pkg.Name, Name: fn.Name.Name, Node: fn, Package: pkg}
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					sel, ok := call.Fun.(*ast.SelectorExpr)
+					if !ok {
+						return true
+					}
+					genPkg, service := grpcClientService(pkg.TypesInfo.Selections[sel])
+					if service == "" {
+						return true
+					}
+					method := sel.Sel.Name
+					link := &grpcClientLink{
+						Caller: caller,
+						Pos:    pkg.Fset.Position(call.Pos()).String(),
+						Method: service + "/" + method,
+					}
+					fullName := grpcFullMethodName(allPkgs[genPkg], service, method)
+					if fullName != "" {
+						link.Method = fullName
+					}
+					for _, ep := range eps {
+						if ep.Kind != EntryGRPC || ep.Service != service || ep.Def.Name != method {
+							continue
+						}
+						if implName := grpcFullMethodName(allPkgs[ep.GenPkg], service, method); (fullName != "" && implName == fullName) ||
+							((fullName == "" || implName == "") && ep.GenPkg == genPkg) {
+							link.Candidates = append(link.Candidates, ep)
+						}
+					}
+					if len(link.Candidates) == 1 {
+						link.Impl = link.Candidates[0]
+					}
+					links = append(links, link)
+					return true
+				})
+			}
+		}
+	}
+	return links
+}
+
+// grpcClientService は選択されたメソッドが生成された <Service>Client インターフェイスのものであれば
+// 生成コードのパッケージパスとサービス名を返す。同じパッケージに New<Service>Client があるものを生成コードとみなす。
+func grpcClientService(sel *types.Selection) (string, string) {
+	if sel == nil || sel.Kind() != types.MethodVal || !types.IsInterface(sel.Recv()) {
+		return "", ""
+	}
+	named := namedType(sel.Recv())
+	if named == nil || named.Obj().Pkg() == nil {
+		return "", ""
+	}
+	service, ok := strings.CutSuffix(named.Obj().Name(), "Client")
+	if !ok || named.Obj().Pkg().Scope().Lookup("New"+named.Obj().Name()) == nil {
+		return "", ""
+	}
+	return named.Obj().Pkg().Path(), service
+}
+
+// grpcFullMethodName は生成コードのパッケージ pkg から、service の method の完全なメソッド名
+// (/example.ExampleService/Culc) を求める。protoc-gen-go-grpc の <Service>_<Method>_FullMethodName 定数を使い、
+// 定数のない古い生成コードでは Invoke や UnaryServerInfo に書かれた文字列リテラルから探す。見つからなければ空文字列。
+func grpcFullMethodName(pkg *packages.Package, service, method string) string {
+	if pkg == nil || pkg.Types == nil {
+		return ""
+	}
+	if c, ok := pkg.Types.Scope().Lookup(service + "_" + method + "_FullMethodName").(*types.Const); ok && c.Val().Kind() == constant.String {
+		return constant.StringVal(c.Val())
+	}
+	suffix := service + "/" + method
+	var found string
+	for _, file := range pkg.Syntax {
+		ast.Inspect(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING || found != "" {
+				return found == ""
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			// /<proto パッケージ>.<Service>/<Method>、proto のパッケージがなければ /<Service>/<Method>
+			if s == "/"+suffix || (strings.HasPrefix(s, "/") && strings.HasSuffix(s, "."+suffix)) {
+				found = s
+			}
+			return true
+		})
+		if found != "" {
+			break
+		}
+	}
+	return found
+}
+
+// printLinkedTree は呼び出しツリーを出力し、gRPC クライアントの呼び出しでは実装メソッドのツリーへ続ける。
+// inProgress は RPC の中から同じ RPC を呼ぶ場合に無限にたどらないよう、展開中の実装メソッドの関数 ID を記録する。
+func printLinkedTree(nodes []*CallNode, depth int, byPos map[string]*grpcClientLink, pkgMap map[string]*packages.Package, inProgress map[string]bool) {
+	indent := strings.Repeat("  ", depth)
+	for _, n := range nodes {
+		fmt.Printf("%sCall: %s\n", indent, n.Label)
+		printLinkedTree(n.Children, depth+1, byPos, pkgMap, inProgress)
+
+		link := byPos[n.Pos]
+		if link != nil && len(link.Candidates) > 1 {
+			fmt.Printf("%s  => ambiguous: %d implementations are registered for %s\n", indent, len(link.Candidates), link.Method)
+			continue
+		}
+		if link == nil || link.Impl == nil {
+			continue
+		}
+		impl := link.Impl.Def
+		fmt.Printf("%s  => [grpc] %s (%s)\n", indent, link.Impl.Name, packageModule(impl.Package))
+		if id := functionID(impl); !inProgress[id] {
+			inProgress[id] = true
+			tree := buildCallTree(impl.Node.Body, impl.Package, pkgMap, make(map[string]bool))
+			printLinkedTree(tree, depth+2, byPos, pkgMap, inProgress)
+			delete(inProgress, id)
+		}
+	}
+}
+
+// packageModule はパッケージが属するモジュールのパスを返す
+func packageModule(pkg *packages.Package) string {
+	if pkg.Module == nil {
+		return "(no module)"
+	}
+	return pkg.Module.Path
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceRepository(t *testing.T) {
+	out := runOutput(t, "workspace", "-work", "../go.work")
+	assertContains(t, out,
+		"main calls /example.YourService/YourRPCMethod\n  => [grpc] server.YourRPCMethod (github.com/shunta-furukawa/zenn-demo/562e8d092d264f)\n",
+	)
+}
+
+func TestWorkspaceFixture(t *testing.T) {
+	work := filepath.Join(fixtureDir(t, "workspace"), "go.work")
+	out := runOutput(t, "workspace", "-work", work)
+
+	calls := entrySection(t, out, "=== gRPC client calls ===")
+	assertContains(t, calls,
+		// 同じ生成パッケージの実装に対応付け、proto のパッケージが違う同名のサービス (other.Greeter) とは区別する
+		"main calls /hello.Greeter/SayHello\n  => [grpc] greeter.SayHello (fixture/server)\n",
+		// 別々に生成したコードでも完全なメソッド名が同じ実装が複数あれば曖昧として報告する
+		"main calls /hello.Greeter/SayBye\n  => ambiguous: 2 implementations are registered for /hello.Greeter/SayBye\n",
+		"     [grpc] legacyGreeter.SayBye (fixture/legacy)\n",
+		"     [grpc] greeter.SayBye (fixture/server)\n",
+	)
+	assertNotContains(t, calls, "otherGreeter")
+
+	assertContains(t, out,
+		"  Call: client.SayHello\n    => [grpc] greeter.SayHello (fixture/server)\n      Call: greet\n",
+		"  Call: client.SayBye\n    => ambiguous: 2 implementations are registered for /hello.Greeter/SayBye\n",
+	)
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// runXref は .proto の service / rpc と、生成コード・実装メソッドを突き合わせたレポートを出力する
+//
+//	go run . xref -dir ./example
+func runXref(args []string) error {
+	fset := flag.NewFlagSet("xref", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+	refs, err := buildProtoXrefs(*dir, pkgs, pkgMap)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("=== Proto to implementation cross reference ===")
+	for _, ref := range refs {
+		fmt.Printf("rpc %s.%s/%s (%s:%d)\n", ref.File.Package, ref.Service.Name, ref.Method.Name, ref.File.Path, ref.Method.Line)
+		if ref.Generated != nil {
+			fmt.Printf("  generated:      %s (%s)\n", ref.Generated.FullName(), ref.GeneratedPos)
+		} else {
+			fmt.Println("  generated:      (not found)")
+		}
+		if ref.Impl == nil {
+			fmt.Println("  implementation: (not found)")
+			continue
+		}
+		fmt.Printf("  implementation: %s.%s (%s)\n", ref.Impl.Def.Package.Name, ref.Impl.Name, ref.Impl.Def.Package.Fset.Position(ref.Impl.Def.Node.Pos()))
+		if ref.FieldPkg == nil {
+			fmt.Println("  fields: (not checked: the generated package registered by the implementation could not be resolved)")
+			continue
+		}
+		fmt.Printf("  request %s\n", ref.Method.Request)
+		printFieldUsage("read", ref.RequestFields, func(u *fieldUsage) []token.Position { return u.Reads })
+		fmt.Printf("  response %s\n", ref.Method.Response)
+		printFieldUsage("set", ref.ResponseFields, func(u *fieldUsage) []token.Position { return u.Writes })
+	}
+	return nil
+}
+
+// protoXref は 1 つの rpc に対応する生成コードと実装の情報
+type protoXref struct {
+	File    *ProtoFile
+	Service *ProtoService
+	Method  *ProtoMethod
+
+	Generated    *types.Func    // 生成された <Service>Server インターフェイスのメソッド
+	GeneratedPos token.Position // Generated の定義位置
+	Impl         *EntryPoint    // サービス登録から見つかった実装メソッド
+	// フィールドの読み書きを調べた生成コードのパッケージ (実装の Register<Service>Server の呼び出し先)。
+	// 実装の import が解決できない場合は nil で、フィールドは調べていない。
+	FieldPkg *packages.Package
+
+	RequestFields  []*fieldUsage // リクエストメッセージの各フィールドの読み書き
+	ResponseFields []*fieldUsage // レスポンスメッセージの各フィールドの読み書き
+}
+
+// fieldUsage はメッセージの 1 フィールドが読まれた位置・書かれた位置
+type fieldUsage struct {
+	Field  *ProtoField
+	Reads  []token.Position
+	Writes []token.Position
+}
+
+// buildProtoXrefs は dir 配下の .proto を読み込み、dir から読み込み済みの Go パッケージ pkgs と突き合わせて
+// rpc ごとの対応関係を組み立てる
+func buildProtoXrefs(dir string, pkgs []*packages.Package, pkgMap map[string]*packages.Package) ([]*protoXref, error) {
+	protoPaths, err := findProtoFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	eps := collectEntryPoints(pkgs, pkgMap)
+
+	var refs []*protoXref
+	for _, path := range protoPaths {
+		file, err := parseProtoFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// go.work で複数のモジュールを読み込むと同じ名前のサービスが複数ありうるので、
+		// .proto が置かれているモジュール (と go_package) の範囲で生成コードと実装を探す
+		mod := moduleOf(pkgs, path)
+		for _, svc := range file.Services {
+			genPkg, iface := findServerInterface(pkgs, file, mod, svc.Name)
+			for _, m := range svc.Methods {
+				ref := &protoXref{File: file, Service: svc, Method: m}
+				if iface != nil {
+					if ref.Generated = interfaceMethod(iface, goCamelCase(m.Name)); ref.Generated != nil {
+						ref.GeneratedPos = genPkg.Fset.Position(ref.Generated.Pos())
+					}
+				}
+				ref.Impl = findImplementation(eps, genPkg, mod, svc.Name, goCamelCase(m.Name))
+				if ref.Impl != nil {
+					ref.FieldPkg = pkgMap[ref.Impl.GenPkg]
+				}
+				if ref.FieldPkg != nil {
+					reached := reachableFuncs(ref.Impl.Def, pkgMap)
+					ref.RequestFields = messageFieldUsage(reached, ref.FieldPkg, file, m.Request)
+					ref.ResponseFields = messageFieldUsage(reached, ref.FieldPkg, file, m.Response)
+				}
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// moduleOf は path を含むモジュールを読み込んだパッケージから探す (入れ子のモジュールでは最も内側のもの)
+func moduleOf(pkgs []*packages.Package, path string) *packages.Module {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	var found *packages.Module
+	for _, pkg := range pkgs {
+		m := pkg.Module
+		if m == nil || m.Dir == "" || (found != nil && len(m.Dir) <= len(found.Dir)) {
+			continue
+		}
+		if rel, err := filepath.Rel(m.Dir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			found = m
+		}
+	}
+	return found
+}
+
+// inModule は pkg がモジュール mod に属しているかを返す (mod が nil なら常に true)
+func inModule(pkg *packages.Package, mod *packages.Module) bool {
+	return mod == nil || (pkg.Module != nil && pkg.Module.Path == mod.Path)
+}
+
+// findServerInterface は生成コードの <Service>Server インターフェイスと、それを含むパッケージを探す。
+// go_package のパッケージが読み込まれていればそれを、なければ .proto と同じモジュール内のパッケージを使う。
+func findServerInterface(pkgs []*packages.Package, file *ProtoFile, mod *packages.Module, service string) (*packages.Package, *types.Interface) {
+	lookup := func(pkg *packages.Package) *types.Interface {
+		obj, ok := pkg.Types.Scope().Lookup(service + "Server").(*types.TypeName)
+		if !ok {
+			return nil
+		}
+		iface, _ := obj.Type().Underlying().(*types.Interface)
+		return iface
+	}
+	for _, pkg := range pkgs {
+		if file.GoPackage != "" && pkg.PkgPath == file.GoPackage {
+			if iface := lookup(pkg); iface != nil {
+				return pkg, iface
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		if inModule(pkg, mod) {
+			if iface := lookup(pkg); iface != nil {
+				return pkg, iface
+			}
+		}
+	}
+	return nil, nil
+}
+
+// findImplementation は service の method を実装している gRPC のエントリーポイントを探す。
+// 登録に使った Register<Service>Server が genPkg のものであれば、別のモジュールの実装でも対応付ける。
+// 登録の import が解決できない実装は、.proto と同じモジュールにある場合だけ対応付ける。
+func findImplementation(eps []*EntryPoint, genPkg *packages.Package, mod *packages.Module, service, method string) *EntryPoint {
+	for _, ep := range eps {
+		if ep.Kind != EntryGRPC || ep.Service != service || ep.Def.Name != method {
+			continue
+		}
+		if ep.GenPkg != "" {
+			if genPkg != nil && ep.GenPkg == genPkg.PkgPath {
+				return ep
+			}
+		} else if mod != nil && inModule(ep.Def.Package, mod) {
+			return ep
+		}
+	}
+	return nil
+}
+
+// interfaceMethod はインターフェイスから名前でメソッドを探す
+func interfaceMethod(iface *types.Interface, name string) *types.Func {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if m := iface.Method(i); m.Name() == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// messageFieldUsage は reached 内の関数で、proto メッセージ msgName に対応する生成型のフィールドが
+// どこで読まれ (x.F / x.GetF())、どこで書かれているか (x.F = v / &T{F: v}) を proto のフィールドごとに返す。
+// oneof のメンバーは生成型では protobuf_oneof のインターフェイス型のフィールドになるので、
+// ラッパー型 Msg_F のフィールドの読み書き (q.F / &Msg_F{F: v}) と x.GetF() で数える。
+func messageFieldUsage(reached map[string]*FunctionDefinition, genPkg *packages.Package, file *ProtoFile, msgName string) []*fieldUsage {
+	msg := file.Message(msgName)
+	if msg == nil {
+		return nil
+	}
+	typeName, ok := genPkg.Types.Scope().Lookup(goCamelCase(msg.Name)).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	// Go のフィールド名 → proto フィールド名 の対応を struct タグ (protobuf:"...,name=a,...") から作る
+	usages := make(map[string]*fieldUsage)
+	byProtoName := make(map[string]*fieldUsage)
+	var result []*fieldUsage
+	for _, f := range msg.Fields {
+		u := &fieldUsage{Field: f}
+		byProtoName[f.Name] = u
+		result = append(result, u)
+	}
+	if st, ok := typeName.Type().Underlying().(*types.Struct); ok {
+		for i := 0; i < st.NumFields(); i++ {
+			if u := byProtoName[protoTagName(st.Tag(i))]; u != nil {
+				usages[st.Field(i).Name()] = u
+			}
+		}
+	}
+	wrappers := make(map[*types.TypeName]map[string]*fieldUsage) // oneof のラッパー型 → そのフィールド
+	for _, u := range result {
+		if u.Field.Oneof == "" {
+			continue
+		}
+		wrapper, goName := oneofWrapper(genPkg, msg, u.Field)
+		if wrapper == nil {
+			continue
+		}
+		usages[goName] = u // メッセージの GetF() で読む場合
+		wrappers[wrapper] = map[string]*fieldUsage{goName: u}
+	}
+
+	for _, fn := range sortedFuncs(reached) {
+		// 生成コード内 (GetA() の本体など) のアクセスは、呼び出し側で数えているので除く
+		if fn.Node.Body != nil && fn.Package != genPkg {
+			collectFieldAccesses(fn, typeName, usages)
+			for wrapper, wrapperUsages := range wrappers {
+				collectFieldAccesses(fn, wrapper, wrapperUsages)
+			}
+		}
+	}
+	return result
+}
+
+// oneofWrapper は oneof のメンバー field に対応する生成コードのラッパー型 (Msg_F) と、その Go のフィールド名を返す。
+// 名前の衝突回避などで見つからない場合は nil を返す。
+func oneofWrapper(genPkg *packages.Package, msg *ProtoMessage, field *ProtoField) (*types.TypeName, string) {
+	goName := goCamelCase(field.Name)
+	wrapper, ok := genPkg.Types.Scope().Lookup(goCamelCase(msg.Name) + "_" + goName).(*types.TypeName)
+	if !ok {
+		return nil, ""
+	}
+	st, ok := wrapper.Type().Underlying().(*types.Struct)
+	if !ok || st.NumFields() != 1 || st.Field(0).Name() != goName || protoTagName(st.Tag(0)) != field.Name {
+		return nil, ""
+	}
+	return wrapper, goName
+}
+
+// collectFieldAccesses は fn の本体で msgType のフィールドへの読み書きを usages に記録する
+func collectFieldAccesses(fn *FunctionDefinition, msgType *types.TypeName, usages map[string]*fieldUsage) {
+	typesInfo := fn.Package.TypesInfo
+	fset := fn.Package.Fset
+
+	// 代入の左辺にあるセレクタは書き込みとして扱う
+	lhs := make(map[ast.Expr]bool)
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.AssignStmt:
+			for _, l := range s.Lhs {
+				lhs[l] = true
+			}
+		case *ast.IncDecStmt:
+			lhs[s.X] = true
+		}
+		return true
+	})
+
+	ast.Inspect(fn.Node.Body, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.SelectorExpr:
+			sel := typesInfo.Selections[e]
+			if sel == nil {
+				return true
+			}
+			named := namedType(sel.Recv())
+			if named == nil || named.Obj() != msgType {
+				return true
+			}
+			pos := fset.Position(e.Sel.Pos())
+			switch sel.Kind() {
+			case types.FieldVal:
+				if u := usages[e.Sel.Name]; u != nil {
+					if lhs[e] {
+						u.Writes = append(u.Writes, pos)
+					} else {
+						u.Reads = append(u.Reads, pos)
+					}
+				}
+			case types.MethodVal:
+				if u := usages[strings.TrimPrefix(e.Sel.Name, "Get")]; u != nil && strings.HasPrefix(e.Sel.Name, "Get") {
+					u.Reads = append(u.Reads, pos)
+				}
+			}
+		case *ast.CompositeLit:
+			tv, ok := typesInfo.Types[e]
+			if !ok {
+				return true
+			}
+			if named := namedType(tv.Type); named == nil || named.Obj() != msgType {
+				return true
+			}
+			for _, elt := range e.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kv.Key.(*ast.Ident); ok && usages[key.Name] != nil {
+					usages[key.Name].Writes = append(usages[key.Name].Writes, fset.Position(key.Pos()))
+				}
+			}
+		}
+		return true
+	})
+}
+
+// protoTagName は生成コードの struct タグ protobuf:"varint,1,opt,name=a,proto3" から name を取り出す
+func protoTagName(tag string) string {
+	for _, part := range strings.Split(reflect.StructTag(tag).Get("protobuf"), ",") {
+		if name, ok := strings.CutPrefix(part, "name="); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// printFieldUsage はフィールドごとの使用位置 (verb: read / set) と、一度も使われていないフィールドを出力する
+func printFieldUsage(verb string, usages []*fieldUsage, positions func(*fieldUsage) []token.Position) {
+	var used, unused []string
+	for _, u := range usages {
+		ps := positions(u)
+		if len(ps) == 0 {
+			unused = append(unused, u.Field.Name)
+			continue
+		}
+		locs := make([]string, len(ps))
+		for i, p := range ps {
+			locs[i] = fmt.Sprintf("%s:%d", p.Filename, p.Line)
+		}
+		used = append(used, fmt.Sprintf("%s (%s)", u.Field.Name, strings.Join(locs, ", ")))
+	}
+	if len(used) > 0 {
+		fmt.Printf("    %s: %s\n", verb, strings.Join(used, ", "))
+	}
+	if len(unused) > 0 {
+		fmt.Printf("    not %s: %s\n", verb, strings.Join(unused, ", "))
+	}
+}
+
+// runUnusedFields は全 RPC 実装を調べ、どの実装からも読まれないリクエストフィールドと
+// どの実装からも設定されないレスポンスフィールドを出力する
+//
+//	go run . unusedfields -dir ./example
+func runUnusedFields(args []string) error {
+	fset := flag.NewFlagSet("unusedfields", flag.ExitOnError)
+	dir := fset.String("dir", "./example", "analysis target directory")
+	fset.Parse(args)
+
+	pkgs, pkgMap, err := loadPackages(*dir, false)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+	refs, err := buildProtoXrefs(*dir, pkgs, pkgMap)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("=== Unused proto fields ===")
+	unread := unusedFields(refs, true)
+	unset := unusedFields(refs, false)
+	for _, u := range unread {
+		fmt.Printf("%s:%d: request field %s.%s is never read by any RPC implementation (%s)\n",
+			u.File.Path, u.Field.Line, u.Message, u.Field.Name, strings.Join(u.RPCs, ", "))
+	}
+	for _, u := range unset {
+		fmt.Printf("%s:%d: response field %s.%s is never set by any RPC implementation (%s)\n",
+			u.File.Path, u.Field.Line, u.Message, u.Field.Name, strings.Join(u.RPCs, ", "))
+	}
+	if len(unread)+len(unset) == 0 {
+		fmt.Println("(no unused fields)")
+	}
+	for _, ref := range refs {
+		switch {
+		case ref.Impl == nil:
+			fmt.Printf("NOTE: %s/%s (%s) has no implementation found; its fields were not checked\n", ref.Service.Name, ref.Method.Name, ref.File.Path)
+		case ref.FieldPkg == nil:
+			fmt.Printf("NOTE: %s/%s (%s) is implemented by %s, but its generated package could not be resolved; its fields were not checked\n",
+				ref.Service.Name, ref.Method.Name, ref.File.Path, ref.Impl.Name)
+		}
+	}
+	return nil
+}
+
+// unusedField はどの RPC 実装からも使われていないフィールド
+type unusedField struct {
+	File    *ProtoFile
+	Message string // パッケージ修飾付きのメッセージ名
+	Field   *ProtoField
+	RPCs    []string // このメッセージを使っている RPC
+}
+
+// unusedFields は request が true ならリクエストとして読まれないフィールドを、
+// false ならレスポンスとして設定されないフィールドを返す。
+// 同じメッセージを複数の RPC が使っている場合は、どれか 1 つでも使っていれば使用済みとみなす。
+func unusedFields(refs []*protoXref, request bool) []*unusedField {
+	type fieldKey struct {
+		file  *ProtoFile
+		msg   string
+		field *ProtoField
+	}
+	type msgKey struct {
+		file *ProtoFile
+		msg  string
+	}
+	used := make(map[fieldKey]bool)
+	rpcs := make(map[msgKey][]string)
+	var keys []fieldKey
+	for _, ref := range refs {
+		if ref.FieldPkg == nil {
+			continue
+		}
+		msg, usages := ref.Method.Response, ref.ResponseFields
+		if request {
+			msg, usages = ref.Method.Request, ref.RequestFields
+		}
+		msg = ref.File.Package + "." + strings.TrimPrefix(msg, ref.File.Package+".")
+		if mk := (msgKey{ref.File, msg}); !slices.Contains(rpcs[mk], ref.Method.Name) {
+			rpcs[mk] = append(rpcs[mk], ref.Method.Name)
+		}
+		for _, u := range usages {
+			key := fieldKey{file: ref.File, msg: msg, field: u.Field}
+			if _, seen := used[key]; !seen {
+				keys = append(keys, key)
+			}
+			if request {
+				used[key] = used[key] || len(u.Reads) > 0
+			} else {
+				used[key] = used[key] || len(u.Writes) > 0
+			}
+		}
+	}
+
+	var result []*unusedField
+	for _, key := range keys {
+		if !used[key] {
+			result = append(result, &unusedField{File: key.file, Message: key.msg, Field: key.field, RPCs: rpcs[msgKey{key.file, key.msg}]})
+		}
+	}
+	return result
+}
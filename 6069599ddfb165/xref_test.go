@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestXrefExample(t *testing.T) {
+	out := runOutput(t, "xref")
+	assertContains(t, out,
+		"rpc example.ExampleService/Culc (example/example/example.proto:8)",
+		"(github.com/shunta-furukawa/zenn-demo/6069599ddfb165/example/example.ExampleServiceServer).Culc",
+		"implementation: server.ExampleServer.Culc",
+		"    read: a (", "b (",
+		"    set: message (",
+	)
+	assertNotContains(t, out, "not read", "not set", "(not found)")
+}
+
+func TestXrefFixture(t *testing.T) {
+	dir := fixtureDir(t, "xref")
+	out := runOutput(t, "xref", "-dir", dir)
+	mainGo, err := filepath.Abs(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, out,
+		"rpc store.StoreService/GetItem",
+		"implementation: main.server.GetItem",
+		// GetId() の呼び出しも読み込みとして数える
+		"    read: id ("+mainGo+":11)\n    not read: locale\n",
+		"    set: item ("+mainGo+":12)\n    not set: etag\n",
+		"    read: page_size ("+mainGo+":17)\n    not read: page_token\n",
+		// 代入 resp.F = v と append の代入も書き込みとして数える
+		"    set: items ("+mainGo+":18), next_page_token ("+mainGo+":20)\n",
+		// 埋め込んだ Unimplemented<Service>Server のメソッドは実装として扱わない
+		"rpc store.StoreService/DeleteItem (testdata/xref/proto/store.proto:10)\n  generated:      (fixture/xref/storepb.StoreServiceServer).DeleteItem",
+		"  implementation: (not found)\n",
+	)
+}
+
+func TestUnusedFieldsExample(t *testing.T) {
+	out := runOutput(t, "unusedfields")
+	assertContains(t, out, "(no unused fields)")
+}
+
+func TestUnusedFieldsFixture(t *testing.T) {
+	out := runOutput(t, "unusedfields", "-dir", fixtureDir(t, "xref"))
+	assertContains(t, out,
+		"store.proto:20: request field store.GetItemRequest.locale is never read by any RPC implementation (GetItem)",
+		"store.proto:30: request field store.ListItemsRequest.page_token is never read by any RPC implementation (ListItems)",
+		"store.proto:25: response field store.GetItemResponse.etag is never set by any RPC implementation (GetItem)",
+		"NOTE: StoreService/DeleteItem (testdata/xref/proto/store.proto) has no implementation found; its fields were not checked",
+	)
+	// Item は RPC のリクエスト・レスポンスとして直接使われていないので対象外
+	assertNotContains(t, out, "store.Item.price", "DeleteItemRequest.id")
+}
+
+func TestUnusedFieldsOneof(t *testing.T) {
+	out := runOutput(t, "unusedfields", "-dir", fixtureDir(t, "oneof"))
+	assertContains(t, out,
+		"search.proto:15: request field search.SearchRequest.user_id is never read",
+		"search.proto:17: request field search.SearchRequest.limit is never read",
+		"search.proto:24: response field search.SearchResponse.empty is never set",
+	)
+	// 型 switch のラッパー型 (q.Tag)・getter (GetText) での読み込みと、ラッパー型の composite literal での設定を数える
+	assertNotContains(t, out, "SearchRequest.text ", "SearchRequest.tag ", "SearchResponse.hit ", "SearchResponse.error_message ")
+}